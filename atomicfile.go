@@ -0,0 +1,91 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// The suffix of backup files
+const backupSuffix = "~"
+
+// Writes a file atomically.  The content is written to a temporary file in the same directory,
+// which is then synced and renamed over the target.  The directory is synced afterwards, so that the
+// rename itself is durable.  If the target already exists, its file mode is preserved.
+func atomicWriteFile(filename string, write func(w io.Writer) error) (err error) {
+	// Write through symbolic links, rather than replacing them
+	if target, err := filepath.EvalSymlinks(filename); err == nil {
+		filename = target
+	}
+
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(filename); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if err := write(tmp); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(filename))
+}
+
+// Syncs a directory, so that changes to its entries are written to disk
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
+
+// Makes a backup of the file, with the backup suffix appended to the filename.  Does nothing if the
+// file does not exist.
+func backupFile(filename string) error {
+	src, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	backupFilename := filename + backupSuffix
+	if err := atomicWriteFile(backupFilename, func(w io.Writer) error {
+		_, err := io.Copy(w, src)
+		return err
+	}); err != nil {
+		return err
+	}
+	return os.Chmod(backupFilename, info.Mode().Perm())
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAtomicWriteFile(t *testing.T) {
+	t.Run("should replace the file and preserve its mode", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "old")
+		assert.NoError(t, os.Chmod(filename, 0600))
+
+		err := atomicWriteFile(filename, func(w io.Writer) error {
+			_, err := fmt.Fprint(w, "new")
+			return err
+		})
+		assert.NoError(t, err)
+
+		assertFileContent(t, filename, "new")
+		info, err := os.Stat(filename)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+		assertNoTempFiles(t, filepath.Dir(filename))
+	})
+
+	t.Run("should leave the file untouched if the write fails", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "old")
+
+		err := atomicWriteFile(filename, func(w io.Writer) error {
+			fmt.Fprint(w, "partial")
+			return errors.New("disk full")
+		})
+		assert.Error(t, err)
+
+		assertFileContent(t, filename, "old")
+		assertNoTempFiles(t, filepath.Dir(filename))
+	})
+}
+
+func TestSyncDir(t *testing.T) {
+	t.Run("should sync the directory", func(t *testing.T) {
+		assert.NoError(t, syncDir(t.TempDir()))
+	})
+
+	t.Run("should return an error if the directory does not exist", func(t *testing.T) {
+		assert.Error(t, syncDir(filepath.Join(t.TempDir(), "missing")))
+	})
+}
+
+func TestBackupFile(t *testing.T) {
+	t.Run("should copy the file to the backup", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "old")
+
+		assert.NoError(t, backupFile(filename))
+		assertFileContent(t, filename+backupSuffix, "old")
+	})
+
+	t.Run("should do nothing if the file does not exist", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "data.csv")
+
+		assert.NoError(t, backupFile(filename))
+		_, err := os.Stat(filename + backupSuffix)
+		assert.True(t, os.IsNotExist(err))
+	})
+}
+
+func assertFileContent(t *testing.T, filename string, expected string) {
+	content, err := ioutil.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(content))
+}
+
+func assertNoTempFiles(t *testing.T, dir string) {
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+}
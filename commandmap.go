@@ -12,7 +12,8 @@ import (
 )
 
 const (
-	ModAlt rune = 1<<31 - 1
+	// Added to keys pressed with the alt key.  This is beyond the range of the special keys.
+	ModAlt rune = 1 << 30
 )
 
 // A command
@@ -20,7 +21,6 @@ type Command struct {
 	Name string
 	Doc  string
 
-	// TODO: Add argument mapping which will fetch properties from the environment
 	Action func(ctx *CommandContext) error
 }
 
@@ -32,12 +32,19 @@ func (cmd *Command) Do(ctx *CommandContext) error {
 // A command mapping
 type CommandMapping struct {
 	Commands    map[string]*Command
-	KeyMappings map[rune]*Command
+	KeyMappings *KeyMapping
+}
+
+// A node of the trie of key mappings.  The command is mapped to the sequence of keys leading to this
+// node, and the children hold the longer sequences which start with that sequence.
+type KeyMapping struct {
+	Command  *Command
+	Children map[rune]*KeyMapping
 }
 
 // Creates a new, empty command mapping
 func NewCommandMapping() *CommandMapping {
-	return &CommandMapping{make(map[string]*Command), make(map[rune]*Command)}
+	return &CommandMapping{make(map[string]*Command), &KeyMapping{}}
 }
 
 // Adds a new command
@@ -47,7 +54,82 @@ func (cm *CommandMapping) Define(name string, doc string, opts string, fn func(c
 
 // Adds a key mapping
 func (cm *CommandMapping) MapKey(key rune, cmd *Command) {
-	cm.KeyMappings[key] = cmd
+	cm.MapKeys([]rune{key}, cmd)
+}
+
+// Adds a mapping of a sequence of keys
+func (cm *CommandMapping) MapKeys(keys []rune, cmd *Command) {
+	node := cm.KeyMappings
+	for _, key := range keys {
+		child := node.Children[key]
+		if child == nil {
+			if node.Children == nil {
+				node.Children = make(map[rune]*KeyMapping)
+			}
+			child = &KeyMapping{}
+			node.Children[key] = child
+		}
+		node = child
+	}
+	node.Command = cmd
+}
+
+// Removes the mapping of a sequence of keys.  Returns false if the keys were not mapped
+func (cm *CommandMapping) UnmapKeys(keys []rune) bool {
+	return cm.KeyMappings.unmap(keys)
+}
+
+func (km *KeyMapping) unmap(keys []rune) bool {
+	if len(keys) == 0 {
+		if km.Command == nil {
+			return false
+		}
+		km.Command = nil
+		return true
+	}
+
+	child := km.Children[keys[0]]
+	if child == nil || !child.unmap(keys[1:]) {
+		return false
+	}
+	if child.Command == nil && len(child.Children) == 0 {
+		delete(km.Children, keys[0])
+	}
+	return true
+}
+
+// Returns a command which evaluates an expression.  If the expression is just the name of a command, this
+// is the command itself.  Otherwise, arguments the command is invoked with are added to the end of the
+// expression.  Returns an error if the expression refers to a command which does not exist.
+func (cm *CommandMapping) ExprCommand(expr string) (*Command, error) {
+	var names []string
+	for _, toks := range parseCommandExpr(expr) {
+		if cm.Commands[toks[0]] == nil {
+			return nil, fmt.Errorf("no such command: %v", toks[0])
+		}
+		names = append(names, toks[0])
+	}
+
+	if len(names) == 0 {
+		return nil, errors.New("no command")
+	} else if len(names) == 1 && strings.TrimSpace(expr) == names[0] {
+		return cm.Commands[names[0]], nil
+	}
+
+	doc := expr
+	if len(names) == 1 {
+		doc = cm.Commands[names[0]].Doc
+	}
+	return &Command{
+		Name: expr,
+		Doc:  doc,
+		Action: func(ctx *CommandContext) error {
+			if len(ctx.Args()) > 0 {
+				return cm.Eval(ctx, expr+" "+joinCommandArgs(ctx.Args()))
+			}
+			return cm.Eval(ctx, expr)
+		},
+	}, nil
 }
 
 // Searches for a command by name.  Returns the command or null
@@ -57,18 +139,32 @@ func (cm *CommandMapping) Command(name string) *Command {
 
 // Searches for a command by key mapping
 func (cm *CommandMapping) KeyMapping(key rune) *Command {
-	return cm.KeyMappings[key]
+	cmd, _ := cm.KeySequenceMapping([]rune{key})
+	return cmd
 }
 
-// Evaluate a command
+// Searches for a command mapped to a sequence of keys.  Returns the command, or nil if there is none, and
+// whether there are longer sequences starting with the keys.
+func (cm *CommandMapping) KeySequenceMapping(keys []rune) (cmd *Command, isPrefix bool) {
+	node := cm.KeyMappings
+	for _, key := range keys {
+		if node = node.Children[key]; node == nil {
+			return nil, false
+		}
+	}
+	return node.Command, len(node.Children) > 0
+}
+
+// Evaluate a command, or a sequence of commands separated by semicolons.  Evaluation stops at the first
+// command which fails.
 func (cm *CommandMapping) Eval(ctx *CommandContext, expr string) error {
 	// TODO: Use propper expression language here
-	toks := shellwords.Split(expr)
-	if len(toks) == 0 {
-		return nil
+	for _, toks := range parseCommandExpr(expr) {
+		if err := cm.Invoke(ctx, toks[0], toks[1:]); err != nil {
+			return err
+		}
 	}
-
-	return cm.Invoke(ctx, toks[0], toks[1:])
+	return nil
 }
 
 func (cm *CommandMapping) Invoke(ctx *CommandContext, name string, args []string) error {
@@ -82,16 +178,16 @@ func (cm *CommandMapping) Invoke(ctx *CommandContext, name string, args []string
 
 // Registers the standard view navigation commands.  These commands require the frame
 func (cm *CommandMapping) RegisterViewCommands() {
-	cm.Define("move-down", "Moves the cursor down one row", "", gridNavOperation(func(grid *ui.Grid) { grid.MoveBy(0, 1) }))
-	cm.Define("move-up", "Moves the cursor up one row", "", gridNavOperation(func(grid *ui.Grid) { grid.MoveBy(0, -1) }))
-	cm.Define("move-left", "Moves the cursor left one column", "", gridNavOperation(func(grid *ui.Grid) { grid.MoveBy(-1, 0) }))
-	cm.Define("move-right", "Moves the cursor right one column", "", gridNavOperation(func(grid *ui.Grid) { grid.MoveBy(1, 0) }))
+	cm.Define("move-down", "Moves the cursor down one row", "", gridMoveOperation(0, 1))
+	cm.Define("move-up", "Moves the cursor up one row", "", gridMoveOperation(0, -1))
+	cm.Define("move-left", "Moves the cursor left one column", "", gridMoveOperation(-1, 0))
+	cm.Define("move-right", "Moves the cursor right one column", "", gridMoveOperation(1, 0))
 
 	// TODO: Pages are just 25 rows and 15 columns at the moment
-	cm.Define("page-down", "Moves the cursor down one page", "", gridNavOperation(func(grid *ui.Grid) { grid.MoveBy(0, 25) }))
-	cm.Define("page-up", "Moves the cursor up one page", "", gridNavOperation(func(grid *ui.Grid) { grid.MoveBy(0, -25) }))
-	cm.Define("page-left", "Moves the cursor left one page", "", gridNavOperation(func(grid *ui.Grid) { grid.MoveBy(-15, 0) }))
-	cm.Define("page-right", "Moves the cursor right one page", "", gridNavOperation(func(grid *ui.Grid) { grid.MoveBy(15, 0) }))
+	cm.Define("page-down", "Moves the cursor down one page", "", gridMoveOperation(0, 25))
+	cm.Define("page-up", "Moves the cursor up one page", "", gridMoveOperation(0, -25))
+	cm.Define("page-left", "Moves the cursor left one page", "", gridMoveOperation(-15, 0))
+	cm.Define("page-right", "Moves the cursor right one page", "", gridMoveOperation(15, 0))
 
 	cm.Define("row-top", "Moves the cursor to the top of the row", "", gridNavOperation(func(grid *ui.Grid) {
 		cellX, _ := grid.CellPosition()
@@ -112,18 +208,88 @@ func (cm *CommandMapping) RegisterViewCommands() {
 		grid.MoveTo(dimX-1, cellY)
 	}))
 
-	cm.Define("delete-row", "Removes the currently selected row", "", func(ctx *CommandContext) error {
-		grid := ctx.Frame().Grid()
-		_, cellY := grid.CellPosition()
+	cm.Define("delete-row", "Removes the currently selected rows, or count rows", "", selectionOperation(func(ctx *CommandContext, cellRange CellRange) error {
+		// Rows hidden by a filter are left alone, and are not included in the count
+		rows := ctx.ModelVC().VisibleRows(cellRange.Row1, cellRange.Row2)
+		if len(rows) < ctx.Count() {
+			modelRows, _ := ctx.ModelVC().Model().Dimensions()
+			rows = ctx.ModelVC().VisibleRows(cellRange.Row1, modelRows-1)
+			rows = rows[:minInt(len(rows), ctx.Count())]
+		}
+		for i := len(rows) - 1; i >= 0; i-- {
+			if err := ctx.ModelVC().DeleteRow(rows[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	cm.Define("delete-col", "Removes the currently selected columns, or count columns", "", selectionOperation(func(ctx *CommandContext, cellRange CellRange) error {
+		if _, cols := ctx.ModelVC().Model().Dimensions(); cellRange.Col2 < cellRange.Col1+ctx.Count()-1 {
+			cellRange.Col2 = cellRange.Col1 + ctx.Count() - 1
+			if cellRange.Col2 >= cols {
+				cellRange.Col2 = cols - 1
+			}
+		}
+		for c := cellRange.Col1; c <= cellRange.Col2; c++ {
+			if err := ctx.ModelVC().DeleteCol(cellRange.Col1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	cm.Define("delete", "Removes the selected rows or columns, or clears the selected cells", "", func(ctx *CommandContext) error {
+		kind, isVisual := ctx.Frame().SelectionKind()
+		switch {
+		case isVisual && kind == VisualRows:
+			return cm.Eval(ctx, "delete-row")
+		case isVisual && kind == VisualCols:
+			return cm.Eval(ctx, "delete-col")
+		default:
+			return cm.Eval(ctx, "clear")
+		}
+	})
+	cm.Define("clear", "Clears the value of the selected cells", "", selectionOperation(func(ctx *CommandContext, cellRange CellRange) error {
+		return setRangeValues(ctx, cellRange, func(string) string { return "" })
+	}))
+	cm.Define("fill", "Sets the selected cells to a value", "", func(ctx *CommandContext) error {
+		if _, isRwModel := ctx.ModelVC().Model().(RWModel); !isRwModel {
+			return errors.New("Model is read-only")
+		}
+
+		cellRange := ctx.Frame().SelectedRange()
+		ctx.Frame().ExitVisualMode()
 
-		return ctx.ModelVC().DeleteRow(cellY)
+		fillRange := func(value string) error {
+			ctx.ModelVC().BeginChangeGroup()
+			defer ctx.ModelVC().EndChangeGroup()
+
+			return setRangeValues(ctx, cellRange, func(string) string { return value })
+		}
+
+		if len(ctx.Args()) == 1 {
+			return fillRange(ctx.Args()[0])
+		}
+		ctx.Frame().Prompt(PromptOptions{Prompt: "fill> "}, fillRange)
+		return nil
 	})
-	cm.Define("delete-col", "Removes the currently selected column", "", func(ctx *CommandContext) error {
-		grid := ctx.Frame().Grid()
-		cellX, _ := grid.CellPosition()
 
-		return ctx.ModelVC().DeleteCol(cellX)
+	cm.Define("visual", "Select a range of cells", "", func(ctx *CommandContext) error {
+		ctx.Frame().EnterVisualMode(VisualCells)
+		return nil
+	})
+	cm.Define("visual-row", "Select a range of rows", "", func(ctx *CommandContext) error {
+		ctx.Frame().EnterVisualMode(VisualRows)
+		return nil
+	})
+	cm.Define("visual-col", "Select a range of columns", "", func(ctx *CommandContext) error {
+		ctx.Frame().EnterVisualMode(VisualCols)
+		return nil
+	})
+	cm.Define("exit-visual", "Clear the selection", "", func(ctx *CommandContext) error {
+		ctx.Frame().ExitVisualMode()
+		return nil
 	})
+
 	cm.Define("search", "Search for a cell", "", func(ctx *CommandContext) error {
 		ctx.Frame().Prompt(PromptOptions{Prompt: "/"}, func(res string) error {
 			re, err := regexp.Compile(res)
@@ -151,7 +317,8 @@ func (cm *CommandMapping) RegisterViewCommands() {
 				cellX = 0
 				cellY = (cellY + 1) % height
 			}
-			if ctx.session.LastSearch.MatchString(ctx.ModelVC().Model().CellValue(cellY, cellX)) {
+			isVisible := ctx.ModelVC().RowAttrs(cellY).Size != 0
+			if isVisible && ctx.session.LastSearch.MatchString(ctx.ModelVC().Model().CellValue(cellY, cellX)) {
 				ctx.Frame().Grid().MoveTo(cellX, cellY)
 				return nil
 			} else if (cellX == startX) && (cellY == startY) {
@@ -172,6 +339,9 @@ func (cm *CommandMapping) RegisterViewCommands() {
 			return fmt.Errorf("invalid regexp: %v", err)
 		}
 
+		ctx.ModelVC().BeginChangeGroup()
+		defer ctx.ModelVC().EndChangeGroup()
+
 		matchCount := 0
 		height, width := ctx.ModelVC().Model().Dimensions()
 		for r := 0; r < height; r++ {
@@ -223,7 +393,7 @@ func (cm *CommandMapping) RegisterViewCommands() {
 		cellX, _ := ctx.Frame().Grid().CellPosition()
 
 		attrs := ctx.ModelVC().ColAttrs(cellX)
-		attrs.Size += 2
+		attrs.Size += 2 * ctx.Count()
 		ctx.ModelVC().SetColAttrs(cellX, attrs)
 		return nil
 	})
@@ -232,7 +402,7 @@ func (cm *CommandMapping) RegisterViewCommands() {
 		cellX, _ := ctx.Frame().Grid().CellPosition()
 
 		attrs := ctx.ModelVC().ColAttrs(cellX)
-		attrs.Size -= 2
+		attrs.Size -= 2 * ctx.Count()
 		if attrs.Size < 4 {
 			attrs.Size = 4
 		}
@@ -240,6 +410,19 @@ func (cm *CommandMapping) RegisterViewCommands() {
 		return nil
 	})
 
+	cm.Define("fit-col", "Fit the width of the current or selected columns to their values", "", func(ctx *CommandContext) error {
+		cellRange := ctx.Frame().SelectedRange()
+		ctx.Frame().ExitVisualMode()
+
+		ctx.Session().FitColWidths(cellRange.Col1, cellRange.Col2)
+		return nil
+	})
+
+	cm.Define("fit-all", "Fit the width of all columns to their values", "", func(ctx *CommandContext) error {
+		ctx.Session().FitColWidths(0, -1)
+		return nil
+	})
+
 	cm.Define("clear-row-marker", "Clears any row markers", "", func(ctx *CommandContext) error {
 		_, cellY := ctx.Frame().Grid().CellPosition()
 
@@ -280,6 +463,8 @@ func (cm *CommandMapping) RegisterViewCommands() {
 		ctx.Frame().Prompt(PromptOptions{
 			Prompt:                 ":",
 			CancelOnEmptyBackspace: true,
+			History:                ctx.Session().CommandHistory,
+			Completer:              ctx.Session().completeCommandLine,
 		}, func(res string) error {
 			return cm.Eval(ctx, res)
 		})
@@ -326,51 +511,90 @@ func (cm *CommandMapping) RegisterViewCommands() {
 		}
 		return nil
 	})
-	cm.Define("yank", "Yank cell value", "", func(ctx *CommandContext) error {
-		grid := ctx.Frame().Grid()
-		cellX, cellY := grid.CellPosition()
-
-		// TODO: allow ranges
-		ctx.Session().pasteBoard.SetCellValue(0, 0, grid.Model().CellValue(cellX, cellY))
+	cm.Define("yank", "Yank the selected cells", "", func(ctx *CommandContext) error {
+		cellRange := ctx.Frame().SelectedRange()
+		ctx.Frame().ExitVisualMode()
+
+		// Rows hidden by a filter are not yanked
+		model := ctx.ModelVC().Model()
+		pasteBoard := ctx.Session().pasteBoard
+		visibleRows := ctx.ModelVC().VisibleRows(cellRange.Row1, cellRange.Row2)
+		rows, cols := len(visibleRows), cellRange.Col2-cellRange.Col1+1
+
+		pasteBoard.Resize(rows, cols)
+		for r, modelRow := range visibleRows {
+			for c := 0; c < cols; c++ {
+				pasteBoard.SetCellValue(r, c, model.CellValue(modelRow, cellRange.Col1+c))
+			}
+		}
 
+		if rows > 1 || cols > 1 {
+			ctx.Frame().ShowMessage(fmt.Sprintf("Yanked %d x %d cells", rows, cols))
+		}
 		return nil
 	})
-	cm.Define("paste", "Paste cell value", "", func(ctx *CommandContext) error {
-		grid := ctx.Frame().Grid()
-		cellX, cellY := grid.CellPosition()
+	cm.Define("paste", "Paste the yanked cells at the selected cell", "", selectionOperation(func(ctx *CommandContext, cellRange CellRange) error {
+		pasteBoard := ctx.Session().pasteBoard
+		pasteRows, pasteCols := pasteBoard.Dimensions()
 
-		// TODO: allow ranges
-		if _, isRwModel := ctx.ModelVC().Model().(RWModel); !isRwModel {
-			return errors.New("Model is read-only")
-		}
-		if err := ctx.ModelVC().SetCellValue(cellY, cellX, ctx.Session().pasteBoard.CellValue(0, 0)); err != nil {
+		// The cells are pasted into the visible rows, growing the model if they will not fit
+		rows := visibleRowsFrom(ctx, cellRange.Row1, pasteRows)
+		if err := growModel(ctx, rows[len(rows)-1]+1, cellRange.Col1+pasteCols); err != nil {
 			return err
 		}
 
+		for r, modelRow := range rows {
+			for c := 0; c < pasteCols; c++ {
+				if err := ctx.ModelVC().SetCellValue(modelRow, cellRange.Col1+c, pasteBoard.CellValue(r, c)); err != nil {
+					return err
+				}
+			}
+		}
 		return nil
-	})
-
-	cm.Define("to-upper", "Convert cell value to uppercase", "", func(ctx *CommandContext) error {
-		grid := ctx.Frame().Grid()
-		cellX, cellY := grid.CellPosition()
+	}))
 
-		// TODO: allow ranges
+	cm.Define("to-upper", "Convert the selected cell values to uppercase", "", selectionOperation(func(ctx *CommandContext, cellRange CellRange) error {
+		return setRangeValues(ctx, cellRange, strings.ToUpper)
+	}))
+	cm.Define("to-lower", "Convert the selected cell values to lowercase", "", selectionOperation(func(ctx *CommandContext, cellRange CellRange) error {
+		return setRangeValues(ctx, cellRange, strings.ToLower)
+	}))
 
+	cm.Define("set-col", "Sets the current or selected columns of each row to the result of an expression", "", func(ctx *CommandContext) error {
 		if _, isRwModel := ctx.ModelVC().Model().(RWModel); !isRwModel {
 			return errors.New("Model is read-only")
 		}
 
-		currentValue := ctx.ModelVC().Model().CellValue(cellY, cellX)
-		newValue := strings.ToUpper(currentValue)
-		if err := ctx.ModelVC().SetCellValue(cellY, cellX, newValue); err != nil {
-			return err
+		// Without a selection, the expression is evaluated for all rows below the header
+		cellRange := ctx.Frame().SelectedRange()
+		if _, isVisual := ctx.Frame().SelectionKind(); !isVisual {
+			rows, _ := ctx.ModelVC().Model().Dimensions()
+			cellRange.Row1, _ = ctx.ModelVC().Header()
+			cellRange.Row2 = rows - 1
+		}
+		ctx.Frame().ExitVisualMode()
+
+		setCol := func(text string) error {
+			expr, err := parseCellExpr(text, ctx.ModelVC().ColumnIndex)
+			if err != nil {
+				return err
+			}
+
+			ctx.ModelVC().BeginChangeGroup()
+			defer ctx.ModelVC().EndChangeGroup()
+
+			return setRangeExpr(ctx, cellRange, expr)
 		}
 
+		if len(ctx.Args()) > 0 {
+			return setCol(commandExprFromArgs(ctx.Args()))
+		}
+		ctx.Frame().Prompt(PromptOptions{Prompt: "="}, setCol)
 		return nil
 	})
 
 	cm.Define("each-row", "Executes the command for each row in the column", "", func(ctx *CommandContext) error {
-		if len(ctx.args) != 1 {
+		if len(ctx.args) == 0 {
 			return errors.New("Sub-command required")
 		}
 
@@ -380,12 +604,16 @@ func (cm *CommandMapping) RegisterViewCommands() {
 		cellX, cellY := grid.CellPosition()
 		defer grid.MoveTo(cellX, cellY)
 
-		subCommand := ctx.args
+		subCommand := commandExprFromArgs(ctx.args)
+
+		ctx.ModelVC().BeginChangeGroup()
+		defer ctx.ModelVC().EndChangeGroup()
 
-		for r := 0; r < rows; r++ {
+		// Rows hidden by a filter are skipped, as moving to them would move to a visible row instead
+		for _, r := range ctx.ModelVC().VisibleRows(0, rows-1) {
 			grid.MoveTo(cellX, r)
 
-			if err := ctx.Session().Commands.Invoke(ctx, subCommand[0], subCommand[1:]); err != nil {
+			if err := ctx.Session().Commands.Eval(ctx, subCommand); err != nil {
 				return fmt.Errorf("at [%d, %d]: %v", cellX, r, err)
 			}
 		}
@@ -393,6 +621,58 @@ func (cm *CommandMapping) RegisterViewCommands() {
 		return nil
 	})
 
+	cm.Define("pipe", "Pipes the selected cells through a shell command, replacing them with its output", "", pipeOperation(func(ctx *CommandContext, cellRange CellRange, command string, comma rune) (string, error) {
+		return pipeRange(ctx, cellRange, command, comma)
+	}))
+	cm.Define("pipe-col", "Pipes the current or selected columns below the header through a shell command", "", pipeOperation(func(ctx *CommandContext, cellRange CellRange, command string, comma rune) (string, error) {
+		rows, _ := ctx.ModelVC().Model().Dimensions()
+		if cellRange.Row1, _ = ctx.ModelVC().Header(); cellRange.Row1 >= rows {
+			return "", errors.New("No rows below the header")
+		}
+		cellRange.Row2 = rows - 1
+		return pipeRange(ctx, cellRange, command, comma)
+	}))
+	cm.Define("pipe-all", "Pipes all the rows below the header through a shell command", "", pipeOperation(func(ctx *CommandContext, cellRange CellRange, command string, comma rune) (string, error) {
+		return pipeModel(ctx, command, comma)
+	}))
+
+	cm.Define("sort", "Sorts the rows by the current column, or a list of columns, in ascending order", "", sortOperation(false))
+	cm.Define("sort-desc", "Sorts the rows by the current column, or a list of columns, in descending order", "", sortOperation(true))
+
+	cm.Define("filter", "Hides rows which do not match a regular expression or comparison", "", func(ctx *CommandContext) error {
+		filterRows := func(args []string) error {
+			cellX, _ := ctx.Frame().Grid().CellPosition()
+			pred, err := parseRowFilter(ctx.ModelVC().Model(), args, cellX, ctx.ModelVC().ColumnIndex)
+			if err != nil {
+				return err
+			}
+
+			headerRows, _ := ctx.ModelVC().Header()
+			ctx.ModelVC().FilterRows(headerRows, pred)
+			return gridNavOperation(func(grid *ui.Grid) { grid.MoveBy(0, 0) })(ctx)
+		}
+
+		if len(ctx.Args()) > 0 {
+			return filterRows(ctx.Args())
+		}
+		ctx.Frame().Prompt(PromptOptions{Prompt: "filter> "}, func(res string) error {
+			return filterRows(shellwords.Split(res))
+		})
+		return nil
+	})
+	cm.Define("unfilter", "Shows all rows hidden by filter", "", func(ctx *CommandContext) error {
+		ctx.ModelVC().Unfilter()
+		return nil
+	})
+
+	cm.Define("undo", "Undo the last change, or count changes", "", repeatedOperation(func(ctx *CommandContext) error {
+		return ctx.ModelVC().Undo()
+	}))
+
+	cm.Define("redo", "Redo the last undone change, or count changes", "", repeatedOperation(func(ctx *CommandContext) error {
+		return ctx.ModelVC().Redo()
+	}))
+
 	cm.Define("save", "Save current file", "", func(ctx *CommandContext) error {
 		var source ModelSource
 		if len(ctx.args) >= 2 {
@@ -404,6 +684,8 @@ func (cm *CommandMapping) RegisterViewCommands() {
 
 			targetFilename := ctx.args[1]
 			source = codecBuilder(targetFilename)
+		} else if len(ctx.args) == 1 {
+			source = detectModelSource(ctx.args[0])
 		} else {
 			source = ctx.Session().Source
 		}
@@ -413,23 +695,184 @@ func (cm *CommandMapping) RegisterViewCommands() {
 			return fmt.Errorf("model is not writable")
 		}
 
+		if fileSource, isFileSource := wSource.(FileModelSource); isFileSource && ctx.Session().Backup {
+			if err := backupFile(fileSource.Path()); err != nil {
+				return fmt.Errorf("cannot backup file: %v", err)
+			}
+		}
+
 		if err := wSource.Write(ctx.ModelVC().Model()); err != nil {
 			return err
 		}
+		if rwModel, isRwModel := ctx.ModelVC().Model().(RWModel); isRwModel {
+			rwModel.ClearDirty()
+		}
 
 		ctx.Frame().Message("Wrote " + wSource.String())
 		ctx.Session().Source = wSource
+		return ctx.Session().SaveViewState()
+	})
+
+	cm.Define("set", "Change a setting, or show the value of settings", "", func(ctx *CommandContext) error {
+		settings := ctx.Session().Settings
+		args := ctx.Args()
+		if len(args) == 1 {
+			if nameValue := strings.SplitN(args[0], "=", 2); len(nameValue) == 2 {
+				args = nameValue
+			}
+		}
+
+		switch len(args) {
+		case 0:
+			values := make([]string, 0)
+			for _, name := range settings.Names() {
+				value, _ := settings.Get(ctx.Session(), name)
+				values = append(values, name+"="+value)
+			}
+			ctx.Frame().ShowMessage(strings.Join(values, " "))
+		case 1:
+			value, err := settings.Get(ctx.Session(), args[0])
+			if err != nil {
+				return err
+			}
+			ctx.Frame().ShowMessage(args[0] + "=" + value)
+		case 2:
+			return settings.Set(ctx.Session(), args[0], args[1])
+		default:
+			return errors.New("Usage: set [SETTING [VALUE]]")
+		}
 		return nil
 	})
 
-	cm.Define("quit", "Quit TED", "", func(ctx *CommandContext) error {
+	cm.Define("map", "Maps a key, or sequence of keys, to a command expression", "", func(ctx *CommandContext) error {
+		if len(ctx.Args()) < 2 {
+			return errors.New("Usage: map KEY COMMAND [ARGS]")
+		}
+
+		keys, err := parseKeys(ctx.Args()[0])
+		if err != nil {
+			return err
+		}
+		cmd, err := cm.ExprCommand(commandExprFromArgs(ctx.Args()[1:]))
+		if err != nil {
+			return err
+		}
+
+		cm.MapKeys(keys, cmd)
+		return nil
+	})
+
+	cm.Define("unmap", "Removes the mapping of a key or sequence of keys", "", func(ctx *CommandContext) error {
+		if len(ctx.Args()) != 1 {
+			return errors.New("Usage: unmap KEY")
+		}
+
+		keys, err := parseKeys(ctx.Args()[0])
+		if err != nil {
+			return err
+		}
+		if !cm.UnmapKeys(keys) {
+			return fmt.Errorf("no such mapping: %v", ctx.Args()[0])
+		}
+		return nil
+	})
+
+	cm.Define("alias", "Defines a new name for a command expression", "", func(ctx *CommandContext) error {
+		if len(ctx.Args()) < 2 {
+			return errors.New("Usage: alias NAME COMMAND [ARGS]")
+		}
+
+		cmd, err := cm.ExprCommand(commandExprFromArgs(ctx.Args()[1:]))
+		if err != nil {
+			return err
+		}
+
+		cm.Commands[ctx.Args()[0]] = cmd
+		return nil
+	})
+
+	cm.Define("help", "Lists the commands with their aliases and keys, or shows the help of a command", "", func(ctx *CommandContext) error {
+		if len(ctx.Args()) == 0 {
+			helps, otherMappings := cm.commandHelp()
+			lines := helpLines(helps, helpKeysCol(helps))
+			if len(otherMappings) > 0 {
+				lines = append(append(lines, "", "Other key mappings:"), otherMappings...)
+			}
+			ctx.Frame().ShowPanel("Help", lines)
+			return nil
+		}
+
+		helps := make([]*commandHelp, 0, len(ctx.Args()))
+		for _, name := range ctx.Args() {
+			help := cm.commandHelpOf(name)
+			if help == nil {
+				return fmt.Errorf("no such command: %v", name)
+			}
+			helps = append(helps, help)
+		}
+		ctx.Frame().ShowPanel("Help: "+strings.Join(ctx.Args(), ", "), helpLines(helps, helpKeysCol(helps)))
+		return nil
+	})
+
+	cm.Define("stats", "Shows statistics of the values of the current column, or the selected cells", "", func(ctx *CommandContext) error {
+		model := ctx.ModelVC().Model()
+		cellRange := ctx.Frame().SelectedRange()
+		rows, cols := cellRange.Dimensions()
+		title := fmt.Sprintf("Stats: %d x %d cells", rows, cols)
+		if _, isVisual := ctx.Frame().SelectionKind(); !isVisual {
+			modelRows, _ := model.Dimensions()
+			cellRange.Row1, _ = ctx.ModelVC().Header()
+			cellRange.Row2 = modelRows - 1
+
+			title = fmt.Sprintf("Stats: column %d", cellRange.Col1)
+			if label := ctx.ModelVC().ColLabel(cellRange.Col1); label != "" {
+				title = "Stats: " + label
+			}
+		}
+		ctx.Frame().ExitVisualMode()
+
+		// The values are copied, so that the model can change while the stats are computed in the background
+		values := make([]string, 0)
+		for r := cellRange.Row1; r <= cellRange.Row2; r++ {
+			if ctx.ModelVC().RowAttrs(r).Size == 0 {
+				continue
+			}
+			for c := cellRange.Col1; c <= cellRange.Col2; c++ {
+				values = append(values, model.CellValue(r, c))
+			}
+		}
+
+		if len(values) <= backgroundStatsValues {
+			ctx.Frame().ShowPanel(title, computeValueStats(values, topStatsValues).lines())
+			return nil
+		}
+
+		panel := ctx.Frame().ShowPanel(title, []string{fmt.Sprintf("Computing stats of %d values...", len(values))})
+		go func() {
+			lines := computeValueStats(values, topStatsValues).lines()
+			ctx.Session().UIManager.Post(func() { panel.Lines = lines })
+		}()
+		return nil
+	})
+
+	cm.Define("quit", "Quit TED, unless there are unsaved changes", "", func(ctx *CommandContext) error {
+		if rwModel, isRwModel := ctx.ModelVC().Model().(RWModel); isRwModel && rwModel.IsDirty() {
+			return errors.New("unsaved changes (use q! to force)")
+		}
+
+		ctx.Session().UIManager.Shutdown()
+		return nil
+	})
+
+	cm.Define("force-quit", "Quit TED without saving changes", "", func(ctx *CommandContext) error {
+		ctx.Session().forceQuit = true
 		ctx.Session().UIManager.Shutdown()
 		return nil
 	})
 
 	cm.Define("save-and-quit", "Save current file, then quit", "", func(ctx *CommandContext) error {
 		if err := cm.Eval(ctx, "save"); err != nil {
-			return nil
+			return err
 		}
 
 		return cm.Eval(ctx, "quit")
@@ -438,7 +881,10 @@ func (cm *CommandMapping) RegisterViewCommands() {
 	// Aliases
 	cm.Commands["w"] = cm.Command("save")
 	cm.Commands["q"] = cm.Command("quit")
+	cm.Commands["q!"] = cm.Command("force-quit")
 	cm.Commands["wq"] = cm.Command("save-and-quit")
+	cm.Commands["!"] = cm.Command("pipe")
+	cm.Commands["map-col"] = cm.Command("set-col")
 }
 
 // Registers the standard view key bindings.  These commands require the frame
@@ -455,6 +901,8 @@ func (cm *CommandMapping) RegisterViewKeyBindings() {
 	cm.MapKey(ui.KeyCtrlK, cm.Command("row-bottom"))
 	cm.MapKey(ui.KeyCtrlJ, cm.Command("col-left"))
 	cm.MapKey(ui.KeyCtrlL, cm.Command("col-right"))
+	cm.MapKeys([]rune("gg"), cm.Command("row-top"))
+	cm.MapKey('G', cm.Command("row-bottom"))
 
 	cm.MapKey(ui.KeyArrowUp, cm.Command("move-up"))
 	cm.MapKey(ui.KeyArrowDown, cm.Command("move-down"))
@@ -468,22 +916,103 @@ func (cm *CommandMapping) RegisterViewKeyBindings() {
 
 	cm.MapKey('O', cm.Command("open-right"))
 	cm.MapKey('D', cm.Command("delete-row"))
+	cm.MapKeys([]rune("dd"), cm.Command("delete-row"))
+	cm.MapKeys([]rune("dc"), cm.Command("delete-col"))
 
 	cm.MapKey('/', cm.Command("search"))
 	cm.MapKey('n', cm.Command("search-next"))
 
+	cm.MapKey('u', cm.Command("undo"))
+	cm.MapKey(ui.KeyCtrlR, cm.Command("redo"))
+
+	cm.MapKey('v', cm.Command("visual"))
+	cm.MapKey('V', cm.Command("visual-row"))
+	cm.MapKey(ui.KeyCtrlV, cm.Command("visual-col"))
+	cm.MapKey(ui.KeyEsc, cm.Command("exit-visual"))
+	cm.MapKey('x', cm.Command("delete"))
+
 	cm.MapKey('y', cm.Command("yank"))
 	cm.MapKey('p', cm.Command("paste"))
 
-	cm.MapKey('0', cm.Command("clear-row-marker"))
-	cm.MapKey('1', cm.Command("mark-row-red"))
-	cm.MapKey('2', cm.Command("mark-row-green"))
-	cm.MapKey('3', cm.Command("mark-row-blue"))
+	cm.MapKeys([]rune("m0"), cm.Command("clear-row-marker"))
+	cm.MapKeys([]rune("m1"), cm.Command("mark-row-red"))
+	cm.MapKeys([]rune("m2"), cm.Command("mark-row-green"))
+	cm.MapKeys([]rune("m3"), cm.Command("mark-row-blue"))
 
 	cm.MapKey('{', cm.Command("dec-col-width"))
 	cm.MapKey('}', cm.Command("inc-col-width"))
+	cm.MapKey('=', cm.Command("fit-col"))
+	cm.MapKeys([]rune("zf"), cm.Command("fit-col"))
+	cm.MapKeys([]rune("zF"), cm.Command("fit-all"))
 
 	cm.MapKey(':', cm.Command("enter-command"))
+	cm.MapKey('!', cm.Command("pipe"))
+	cm.MapKey('?', cm.Command("help"))
+}
+
+// Parses a command expression into the tokens of each command.  Commands are separated by semicolons
+// which are not within quotes.  Empty commands are skipped.
+func parseCommandExpr(expr string) [][]string {
+	cmds := make([][]string, 0)
+	addCmd := func(s string) {
+		if toks := shellwords.Split(s); len(toks) > 0 {
+			// As in Vim, the shell command can immediately follow the "!", as in "!sort"
+			if len(toks[0]) > 1 && strings.HasPrefix(toks[0], "!") {
+				toks = append([]string{"!", toks[0][1:]}, toks[1:]...)
+			}
+			cmds = append(cmds, toks)
+		}
+	}
+
+	var quote rune
+	start := 0
+	for i, r := range expr {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == ';':
+			addCmd(expr[start:i])
+			start = i + 1
+		}
+	}
+	addCmd(expr[start:])
+	return cmds
+}
+
+// Returns the command expression of arguments.  A single argument is taken to be the expression itself,
+// so that a quoted expression can include a sequence of commands.  Otherwise, the arguments are joined,
+// keeping each one as a single token.
+func commandExprFromArgs(args []string) string {
+	if len(args) == 1 {
+		return args[0]
+	}
+	return joinCommandArgs(args)
+}
+
+// Joins arguments into a string, quoting any arguments which will not be read back as a single token
+func joinCommandArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = quoteCommandArg(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+func quoteCommandArg(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\r\n;'\"") {
+		return arg
+	}
+
+	// Double quotes cannot appear within double quotes, so they are placed within single quotes
+	parts := strings.Split(arg, `"`)
+	for i, part := range parts {
+		parts[i] = `"` + part + `"`
+	}
+	return strings.Join(parts, `'"'`)
 }
 
 // A nativation command factory.  This will perform the passed in operation with the current grid and
@@ -495,3 +1024,178 @@ func gridNavOperation(op func(grid *ui.Grid)) func(ctx *CommandContext) error {
 		return nil
 	}
 }
+
+// A movement command factory.  This moves the cursor by the offset, multiplied by the count.
+func gridMoveOperation(dx, dy int) func(ctx *CommandContext) error {
+	return func(ctx *CommandContext) error {
+		return gridNavOperation(func(grid *ui.Grid) { grid.MoveBy(dx*ctx.Count(), dy*ctx.Count()) })(ctx)
+	}
+}
+
+// A repeated command factory.  This performs the operation count times, stopping early if it fails after
+// succeeding at least once, then redisplays the current cell.
+func repeatedOperation(op func(ctx *CommandContext) error) func(ctx *CommandContext) error {
+	return func(ctx *CommandContext) error {
+		for i := 0; i < ctx.Count(); i++ {
+			if err := op(ctx); err != nil {
+				if i == 0 {
+					return err
+				}
+				break
+			}
+		}
+		return gridNavOperation(func(grid *ui.Grid) { grid.MoveBy(0, 0) })(ctx)
+	}
+}
+
+// A selection command factory.  This will perform the passed in operation over the selected range of cells
+// as a single undoable change, leaving visual mode once the range is determined.
+func selectionOperation(op func(ctx *CommandContext, cellRange CellRange) error) func(ctx *CommandContext) error {
+	return func(ctx *CommandContext) error {
+		if _, isRwModel := ctx.ModelVC().Model().(RWModel); !isRwModel {
+			return errors.New("Model is read-only")
+		}
+
+		cellRange := ctx.Frame().SelectedRange()
+		ctx.Frame().ExitVisualMode()
+
+		ctx.ModelVC().BeginChangeGroup()
+		defer ctx.ModelVC().EndChangeGroup()
+
+		return op(ctx, cellRange)
+	}
+}
+
+// A pipe command factory.  The operation pipes cells through the shell command given by the arguments,
+// or entered at a prompt if there are none, as a single undoable change.  The first line the command
+// writes to standard error, if any, is shown once it succeeds.
+func pipeOperation(op func(ctx *CommandContext, cellRange CellRange, command string, comma rune) (string, error)) func(ctx *CommandContext) error {
+	return func(ctx *CommandContext) error {
+		if _, isRwModel := ctx.ModelVC().Model().(RWModel); !isRwModel {
+			return errors.New("Model is read-only")
+		}
+
+		cellRange := ctx.Frame().SelectedRange()
+		ctx.Frame().ExitVisualMode()
+
+		pipeCells := func(command string) error {
+			if strings.TrimSpace(command) == "" {
+				return errors.New("Shell command required")
+			}
+
+			ctx.ModelVC().BeginChangeGroup()
+			defer ctx.ModelVC().EndChangeGroup()
+
+			stderr, err := op(ctx, cellRange, command, pipeCodecs[ctx.Session().PipeCodec])
+			if err != nil {
+				return err
+			}
+
+			gridNavOperation(func(grid *ui.Grid) { grid.MoveBy(0, 0) })(ctx)
+			if msg := firstLine(stderr); msg != "" {
+				ctx.Frame().Error(errors.New(msg))
+			}
+			return nil
+		}
+
+		if len(ctx.Args()) > 0 {
+			return pipeCells(shellCommandFromArgs(ctx.Args()))
+		}
+		ctx.Frame().Prompt(PromptOptions{Prompt: "!"}, pipeCells)
+		return nil
+	}
+}
+
+// A sort command factory.  The rows are sorted by the columns listed in the arguments, or the current column
+// if there are none.  Columns without a direction of their own are sorted in descending order if descending
+// is true, and ascending order otherwise.
+func sortOperation(descending bool) func(ctx *CommandContext) error {
+	return func(ctx *CommandContext) error {
+		var keys []SortKey
+		if len(ctx.Args()) > 0 {
+			var err error
+			if keys, err = parseSortKeys(strings.Join(ctx.Args(), ","), descending, ctx.ModelVC().ColumnIndex); err != nil {
+				return err
+			}
+		} else {
+			cellX, _ := ctx.Frame().Grid().CellPosition()
+			keys = []SortKey{{Col: cellX, Descending: descending}}
+		}
+
+		fromRow, _ := ctx.ModelVC().Header()
+		if err := ctx.ModelVC().SortRows(keys, fromRow); err != nil {
+			return err
+		}
+		return gridNavOperation(func(grid *ui.Grid) { grid.MoveBy(0, 0) })(ctx)
+	}
+}
+
+// Sets each cell within the range to the result of evaluating the expression for the cell, skipping rows
+// hidden by a filter.  All values are computed before any are set, so that the expression refers to the
+// values before the change.
+func setRangeExpr(ctx *CommandContext, cellRange CellRange, expr cellExpr) error {
+	model := ctx.ModelVC().Model()
+	rows, cols := cellRange.Dimensions()
+	values := make([]string, 0, maxInt(rows*cols, 0))
+	for r := cellRange.Row1; r <= cellRange.Row2; r++ {
+		if ctx.ModelVC().RowAttrs(r).Size == 0 {
+			continue
+		}
+		for c := cellRange.Col1; c <= cellRange.Col2; c++ {
+			value, err := expr.Eval(model, r, c)
+			if err != nil {
+				return fmt.Errorf("at [%d, %d]: %v", c, r, err)
+			}
+			values = append(values, value)
+		}
+	}
+
+	for r := cellRange.Row1; r <= cellRange.Row2; r++ {
+		if ctx.ModelVC().RowAttrs(r).Size == 0 {
+			continue
+		}
+		for c := cellRange.Col1; c <= cellRange.Col2; c++ {
+			if err := ctx.ModelVC().SetCellValue(r, c, values[0]); err != nil {
+				return err
+			}
+			values = values[1:]
+		}
+	}
+	return nil
+}
+
+// Returns count rows which are not hidden by a filter, starting from the row.  Rows beyond the end of the
+// model are included if there are not enough visible rows within it.
+func visibleRowsFrom(ctx *CommandContext, row int, count int) []int {
+	modelRows, _ := ctx.ModelVC().Model().Dimensions()
+	rows := make([]int, 0, count)
+	for r := row; len(rows) < count; r++ {
+		if r >= modelRows || ctx.ModelVC().RowAttrs(r).Size != 0 {
+			rows = append(rows, r)
+		}
+	}
+	return rows
+}
+
+// Grows the model so that it has at least the number of rows and columns.
+func growModel(ctx *CommandContext, rows, cols int) error {
+	height, width := ctx.ModelVC().Model().Dimensions()
+	if rows <= height && cols <= width {
+		return nil
+	}
+	return ctx.ModelVC().Resize(maxInt(rows, height), maxInt(cols, width))
+}
+
+// Sets each cell within the range to the result of calling fn with the current value, skipping rows hidden
+// by a filter.
+func setRangeValues(ctx *CommandContext, cellRange CellRange, fn func(value string) string) error {
+	model := ctx.ModelVC().Model()
+	for _, r := range ctx.ModelVC().VisibleRows(cellRange.Row1, cellRange.Row2) {
+		for c := cellRange.Col1; c <= cellRange.Col2; c++ {
+			if err := ctx.ModelVC().SetCellValue(r, c, fn(model.CellValue(r, c))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
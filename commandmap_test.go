@@ -0,0 +1,112 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/lmika/shellwords"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandMapping_KeySequenceMapping(t *testing.T) {
+	cm := NewCommandMapping()
+	cm.Define("top", "", "", func(ctx *CommandContext) error { return nil })
+	cm.Define("delete", "", "", func(ctx *CommandContext) error { return nil })
+	cm.Define("delete-row", "", "", func(ctx *CommandContext) error { return nil })
+
+	cm.MapKeys([]rune("gg"), cm.Command("top"))
+	cm.MapKey('d', cm.Command("delete"))
+	cm.MapKeys([]rune("dd"), cm.Command("delete-row"))
+
+	t.Run("should find commands of sequences", func(t *testing.T) {
+		cmd, isPrefix := cm.KeySequenceMapping([]rune("gg"))
+		assert.Equal(t, cm.Command("top"), cmd)
+		assert.False(t, isPrefix)
+
+		cmd, isPrefix = cm.KeySequenceMapping([]rune("g"))
+		assert.Nil(t, cmd)
+		assert.True(t, isPrefix)
+
+		cmd, isPrefix = cm.KeySequenceMapping([]rune("d"))
+		assert.Equal(t, cm.Command("delete"), cmd)
+		assert.True(t, isPrefix)
+
+		cmd, isPrefix = cm.KeySequenceMapping([]rune("gx"))
+		assert.Nil(t, cmd)
+		assert.False(t, isPrefix)
+	})
+
+	t.Run("should remove mappings and prefixes which are no longer used", func(t *testing.T) {
+		assert.False(t, cm.UnmapKeys([]rune("g")))
+		assert.True(t, cm.UnmapKeys([]rune("gg")))
+		assert.True(t, cm.UnmapKeys([]rune("d")))
+
+		_, isPrefix := cm.KeySequenceMapping([]rune("g"))
+		assert.False(t, isPrefix)
+
+		cmd, isPrefix := cm.KeySequenceMapping([]rune("d"))
+		assert.Nil(t, cmd)
+		assert.True(t, isPrefix)
+	})
+}
+
+func TestCommandMapping_ExprCommand(t *testing.T) {
+	var invoked [][]string
+	cm := NewCommandMapping()
+	cm.Define("echo", "Echoes", "", func(ctx *CommandContext) error {
+		invoked = append(invoked, ctx.Args())
+		return nil
+	})
+
+	t.Run("should return the command if the expression is only its name", func(t *testing.T) {
+		cmd, err := cm.ExprCommand("echo")
+
+		assert.NoError(t, err)
+		assert.Equal(t, cm.Command("echo"), cmd)
+	})
+
+	t.Run("should evaluate the expression with the arguments added", func(t *testing.T) {
+		invoked = nil
+		cmd, err := cm.ExprCommand(`echo a; echo "b c"`)
+		assert.NoError(t, err)
+
+		assert.NoError(t, cmd.Do(&CommandContext{args: []string{"d;e"}}))
+		assert.Equal(t, [][]string{{"a"}, {"b c", "d;e"}}, invoked)
+	})
+
+	t.Run("should return an error for unknown commands", func(t *testing.T) {
+		for _, expr := range []string{"", " ; ", "echo; nope"} {
+			_, err := cm.ExprCommand(expr)
+			assert.Error(t, err, expr)
+		}
+	})
+}
+
+func TestParseCommandExpr(t *testing.T) {
+	scenarios := []struct {
+		expr     string
+		expected [][]string
+	}{
+		{"", [][]string{}},
+		{"save", [][]string{{"save"}}},
+		{"fill 'a b'; to-upper", [][]string{{"fill", "a b"}, {"to-upper"}}},
+		{`fill "a;b";;  move-down  ;`, [][]string{{"fill", "a;b"}, {"move-down"}}},
+		{`fill "it's"; fill '"'`, [][]string{{"fill", "it's"}, {"fill", `"`}}},
+		{"!sort -r; q!", [][]string{{"!", "sort", "-r"}, {"q!"}}},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.expr, func(t *testing.T) {
+			assert.Equal(t, scenario.expected, parseCommandExpr(scenario.expr))
+		})
+	}
+}
+
+func TestJoinCommandArgs(t *testing.T) {
+	args := []string{"plain", "", "a b", "semi;colon", `say "hi"`, "it's"}
+
+	joined := joinCommandArgs(args)
+
+	assert.Equal(t, args, shellwords.Split(joined))
+	assert.Equal(t, [][]string{args}, parseCommandExpr(joined))
+}
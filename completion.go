@@ -0,0 +1,164 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/lmika/ted/ui"
+)
+
+// Completes a command line.  The first word of the command after the last semicolon completes to the
+// name of a command, and the arguments of some commands complete to codecs, settings, commands or
+// file paths.  Returns the offset of the word being completed, and the candidates which can replace it.
+func (session *Session) completeCommandLine(text string) (int, []ui.Completion) {
+	words, start, inQuote := commandLineWords(text)
+	if inQuote {
+		return 0, nil
+	}
+
+	word := words[len(words)-1]
+	if len(words) == 1 {
+		return start, completeNames(commandNames(session.Commands), word)
+	}
+
+	cmd := session.Commands.Command(words[0])
+	if cmd == nil {
+		return 0, nil
+	}
+
+	args := words[1 : len(words)-1]
+	switch cmd.Name {
+	case "save":
+		if len(args) == 0 {
+			return start, append(completeNames(codecNames(), word), completeFilePath(word)...)
+		} else if _, isCodec := codecModelSourceBuilders[args[0]]; isCodec && len(args) == 1 {
+			return start, completeFilePath(word)
+		}
+	case "set":
+		if len(args) == 0 {
+			return start, completeNames(session.Settings.Names(), word)
+		}
+	case "each-row", "help":
+		if len(args) == 0 {
+			return start, completeNames(commandNames(session.Commands), word)
+		}
+	case "map", "alias":
+		if len(args) == 1 {
+			return start, completeNames(commandNames(session.Commands), word)
+		}
+	}
+	return 0, nil
+}
+
+// Splits the command line into the words of the command after the last semicolon.  The last word is
+// the one being completed, and is empty if the text ends with a space.  Returns the words, the offset
+// of the last word, and whether the last word is within quotes.
+func commandLineWords(text string) (words []string, start int, inQuote bool) {
+	var quote rune
+	var word strings.Builder
+	inWord := false
+	for i, r := range text {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == ';':
+			words, inWord = nil, false
+			word.Reset()
+			start = i + 1
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+			start = i + 1
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	return append(words, word.String()), start, quote != 0
+}
+
+// Returns the names starting with the prefix as completions, each followed by a space
+func completeNames(names []string, prefix string) []ui.Completion {
+	completions := make([]ui.Completion, 0)
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) {
+			completions = append(completions, ui.Completion{Text: name + " ", Label: name})
+		}
+	}
+	return completions
+}
+
+// Returns the names of all commands in sorted order
+func commandNames(cm *CommandMapping) []string {
+	names := make([]string, 0, len(cm.Commands))
+	for name := range cm.Commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Returns the names of all codecs in sorted order
+func codecNames() []string {
+	names := make([]string, 0, len(codecModelSourceBuilders))
+	for name := range codecModelSourceBuilders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Returns the files and directories starting with the path as completions.  Directories are followed
+// by a slash, so that they can be completed further, and files by a space.  Hidden files are only
+// included if the name being completed starts with a dot.
+func completeFilePath(path string) []ui.Completion {
+	dir, prefix := path[:strings.LastIndex(path, "/")+1], path[strings.LastIndex(path, "/")+1:]
+
+	listDir := dir
+	if listDir == "" {
+		listDir = "."
+	} else if strings.HasPrefix(listDir, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			listDir = filepath.Join(home, listDir[2:])
+		}
+	}
+
+	infos, err := ioutil.ReadDir(listDir)
+	if err != nil {
+		return nil
+	}
+
+	completions := make([]ui.Completion, 0)
+	for _, info := range infos {
+		name := info.Name()
+		if !strings.HasPrefix(name, prefix) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(prefix, ".")) {
+			continue
+		}
+
+		isDir := info.IsDir()
+		if info.Mode()&os.ModeSymlink != 0 {
+			if target, err := os.Stat(filepath.Join(listDir, name)); err == nil {
+				isDir = target.IsDir()
+			}
+		}
+
+		if isDir {
+			completions = append(completions, ui.Completion{Text: dir + name + "/", Label: name + "/"})
+		} else {
+			completions = append(completions, ui.Completion{Text: dir + name + " ", Label: name})
+		}
+	}
+	return completions
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lmika/ted/ui"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandLineWords(t *testing.T) {
+	scenarios := []struct {
+		text          string
+		expectedWords []string
+		expectedStart int
+	}{
+		{"", []string{""}, 0},
+		{"sa", []string{"sa"}, 0},
+		{"save ", []string{"save", ""}, 5},
+		{"save  csv fi", []string{"save", "csv", "fi"}, 10},
+		{"fill 'a b' x", []string{"fill", "a b", "x"}, 11},
+		{"fill ''  ", []string{"fill", "", ""}, 9},
+		{"sort 1; fit", []string{"fit"}, 8},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.text, func(t *testing.T) {
+			words, start, inQuote := commandLineWords(scenario.text)
+
+			assert.Equal(t, scenario.expectedWords, words)
+			assert.Equal(t, scenario.expectedStart, start)
+			assert.False(t, inQuote)
+		})
+	}
+
+	t.Run("should indicate if the last word is quoted", func(t *testing.T) {
+		_, _, inQuote := commandLineWords(`fill "a b`)
+		assert.True(t, inQuote)
+	})
+}
+
+func TestSession_CompleteCommandLine(t *testing.T) {
+	session := &Session{Commands: NewCommandMapping(), Settings: NewSettingMapping()}
+	session.Commands.RegisterViewCommands()
+	session.Settings.RegisterSessionSettings()
+
+	dir := t.TempDir()
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "data"), 0755))
+	for _, name := range []string{"data.csv", "other.txt", ".hidden"} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), nil, 0644))
+	}
+
+	scenarios := []struct {
+		text          string
+		expectedStart int
+		expected      []string
+	}{
+		{"sort", 0, []string{"sort ", "sort-desc "}},
+		{"fit-col; to-", 9, []string{"to-lower ", "to-upper "}},
+		{"w ts", 2, []string{"tsv "}},
+		{"save mark", 5, []string{"markdown "}},
+		{"save " + dir + "/d", 5, []string{dir + "/data/", dir + "/data.csv "}},
+		{"save tsv " + dir + "/", 9, []string{dir + "/data/", dir + "/data.csv ", dir + "/other.txt "}},
+		{"save tsv " + dir + "/.h", 9, []string{dir + "/.hidden "}},
+		{"set fit", 4, []string{"fit-on-load "}},
+		{"map gs sort-", 7, []string{"sort-desc "}},
+		{"each-row to-u", 9, []string{"to-upper "}},
+		{"fill ", 0, nil},
+		{"nope x", 0, nil},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.text, func(t *testing.T) {
+			start, completions := session.completeCommandLine(scenario.text)
+
+			var texts []string
+			for _, c := range completions {
+				texts = append(texts, c.Text)
+			}
+			assert.Equal(t, scenario.expectedStart, start)
+			assert.Equal(t, scenario.expected, texts)
+		})
+	}
+
+	t.Run("should label paths by their names", func(t *testing.T) {
+		_, completions := session.completeCommandLine("w " + dir + "/data")
+
+		assert.Equal(t, []ui.Completion{
+			{Text: dir + "/data/", Label: "data/"},
+			{Text: dir + "/data.csv ", Label: "data.csv"},
+		}, completions)
+	})
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Returns the path of the startup configuration file, which is $XDG_CONFIG_HOME/ted/tedrc, or
+// ~/.config/ted/tedrc if that is not set.
+func configPath() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "ted", "tedrc"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "ted", "tedrc"), nil
+}
+
+// LoadConfig evaluates each line of a configuration file as a command.  Blank lines, and lines starting
+// with '#' or '"', are ignored.  A missing file is not an error.  Every line is evaluated, even if some
+// fail, and the error of the first line that failed is returned.
+func (session *Session) LoadConfig(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var firstErr error
+	ctx := &CommandContext{session: session}
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "\"") {
+			continue
+		}
+
+		if err := session.Commands.Eval(ctx, line); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%v:%d: %v", filepath.Base(path), lineNo, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return firstErr
+}
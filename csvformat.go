@@ -0,0 +1,224 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// The UTF-8 byte order mark
+const utf8BOM = "\xef\xbb\xbf"
+
+// The formatting of a CSV file, as detected when the file was read.  This is used to reproduce the
+// formatting when the file is written, so that records which have not changed remain identical.
+type csvFileFormat struct {
+	// The content of the file, without the byte order mark
+	content string
+
+	bom             bool
+	lineEnding      string
+	trailingNewline bool
+
+	// The records of the file, in the order they were read
+	records      []csvRawRecord
+	recordsByKey map[string][]int
+	colQuoted    []bool
+
+	// Blank lines and comments following the last record
+	trailing string
+
+	// The options used to read the file
+	options CsvFileModelSourceOptions
+}
+
+// A record of a CSV file as it originally appeared in the file
+type csvRawRecord struct {
+	// Blank lines and comments preceding the record
+	leading string
+
+	// The text of the record, without the line ending
+	text string
+
+	values []string
+	quoted []bool
+}
+
+func newCsvFileFormat(data string) *csvFileFormat {
+	format := &csvFileFormat{
+		lineEnding:   "\n",
+		recordsByKey: make(map[string][]int),
+	}
+
+	if strings.HasPrefix(data, utf8BOM) {
+		format.bom = true
+		data = data[len(utf8BOM):]
+	}
+	format.content = data
+
+	if nl := strings.IndexByte(data, '\n'); nl > 0 && data[nl-1] == '\r' {
+		format.lineEnding = "\r\n"
+	}
+	format.trailingNewline = data == "" || strings.HasSuffix(data, "\n")
+
+	return format
+}
+
+func (f *csvFileFormat) addRecord(record csvRawRecord) {
+	key := csvRecordKey(record.values)
+	f.recordsByKey[key] = append(f.recordsByKey[key], len(f.records))
+	f.records = append(f.records, record)
+}
+
+// Searches for an unused record with the given values.  The record originally at the same row is
+// preferred, otherwise the first unused record with the same values is returned.
+func (f *csvFileFormat) findRecord(row int, values []string, used map[int]bool) (int, bool) {
+	if row < len(f.records) && !used[row] && stringSlicesEqual(f.records[row].values, values) {
+		return row, true
+	}
+
+	for _, idx := range f.recordsByKey[csvRecordKey(values)] {
+		if !used[idx] {
+			return idx, true
+		}
+	}
+	return 0, false
+}
+
+// Returns which fields of a new or modified record should be quoted.  If the record at the same row
+// had the same number of fields, its quoting is used.  Otherwise, fields are quoted if they were
+// quoted in most of the records of the file.
+func (f *csvFileFormat) quotedFields(row int, fieldCount int) []bool {
+	if row < len(f.records) && len(f.records[row].quoted) == fieldCount {
+		return f.records[row].quoted
+	}
+
+	if f.colQuoted == nil {
+		quotedCount := make([]int, 0)
+		for _, record := range f.records {
+			for c, quoted := range record.quoted {
+				if c >= len(quotedCount) {
+					quotedCount = append(quotedCount, 0)
+				}
+				if quoted {
+					quotedCount[c]++
+				}
+			}
+		}
+
+		f.colQuoted = make([]bool, len(quotedCount))
+		for c, count := range quotedCount {
+			f.colQuoted[c] = count*2 > len(f.records)
+		}
+	}
+	return f.colQuoted
+}
+
+// Splits the content of a CSV file into records.  Each record includes the line ending.  Line endings
+// within quoted fields do not split the record.  Comment lines are returned as records of their own.
+func splitCsvRecords(content string, options CsvFileModelSourceOptions) []string {
+	records := make([]string, 0)
+	for len(content) > 0 {
+		n := len(content)
+		if options.Comment != 0 && strings.HasPrefix(content, string(options.Comment)) {
+			if nl := strings.IndexByte(content, '\n'); nl >= 0 {
+				n = nl + 1
+			}
+		} else {
+			n, _ = scanCsvRecord(content, options)
+		}
+
+		records = append(records, content[:n])
+		content = content[n:]
+	}
+	return records
+}
+
+// Returns which fields of the record text were quoted
+func csvQuotedFields(text string, options CsvFileModelSourceOptions) []bool {
+	_, quoted := scanCsvRecord(text, options)
+	return quoted
+}
+
+// Scans the first record of CSV content, following the rules of csv.Reader.  A quote only starts a quoted
+// field at the start of a field, and within a quoted field, a doubled quote stands for a quote.  With the
+// LazyQuotes option, a quote within a quoted field which is not followed by a delimiter or line ending is
+// also taken as it is.  Returns the length of the record including the line ending, and which fields of
+// the record were quoted.
+func scanCsvRecord(content string, options CsvFileModelSourceOptions) (int, []bool) {
+	quoted := make([]bool, 0)
+	atFieldStart := true
+	inQuotes := false
+
+	for i, w := 0, 0; i < len(content); i += w {
+		var r rune
+		r, w = utf8.DecodeRuneInString(content[i:])
+
+		if atFieldStart {
+			if options.TrimLeadingSpace && (r == ' ' || r == '\t') && r != options.Comma {
+				continue
+			}
+			atFieldStart = false
+			quoted = append(quoted, r == '"')
+			if r == '"' {
+				inQuotes = true
+				continue
+			}
+		}
+
+		switch {
+		case inQuotes:
+			if r != '"' {
+				continue
+			}
+
+			next, _ := utf8.DecodeRuneInString(content[i+w:])
+			switch {
+			case next == '"':
+				w++
+			case options.LazyQuotes && i+w < len(content) && next != options.Comma && next != '\n' && next != '\r':
+				// A quote which does not end the field
+			default:
+				inQuotes = false
+			}
+		case r == options.Comma:
+			atFieldStart = true
+		case r == '\n':
+			return i + w, quoted
+		}
+	}
+
+	if atFieldStart {
+		// The record ends with an empty field
+		quoted = append(quoted, false)
+	}
+	return len(content), quoted
+}
+
+func trimLineEnding(raw string) string {
+	raw = strings.TrimSuffix(raw, "\n")
+	return strings.TrimSuffix(raw, "\r")
+}
+
+func csvRecordKey(values []string) string {
+	return strings.Join(values, "\x1f")
+}
+
+func stringSlicesEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Replaces the line endings within the text with the given line ending
+func replaceLineEndings(text string, lineEnding string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if lineEnding != "\n" {
+		text = strings.ReplaceAll(text, "\n", lineEnding)
+	}
+	return text
+}
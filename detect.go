@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The number of lines read from the start of a file when detecting the codec
+const sniffLineCount = 20
+
+// The delimiters considered when sniffing a delimited file, in order of preference
+var sniffDelimiters = []rune{',', '\t', ';', '|'}
+
+// Codecs implied by the filename extension
+var codecsByExtension = map[string]string{
+	".csv":      "csv",
+	".tsv":      "tsv",
+	".tab":      "tsv",
+	".md":       "markdown",
+	".markdown": "markdown",
+	".jira":     "jira",
+}
+
+// detectModelSource determines the model source for a file from the filename extension.  If the
+// extension is unknown, or is for a delimited file, the first few lines of the file are used to
+// determine the codec and delimiter.
+func detectModelSource(filename string) ModelSource {
+	lines := readSniffLines(filename)
+
+	codecName, hasExtCodec := codecsByExtension[strings.ToLower(filepath.Ext(filename))]
+	if !hasExtCodec {
+		codecName = sniffCodec(lines)
+	}
+
+	switch codecName {
+	case "csv":
+		if delim, found := sniffDelimiter(lines); found {
+			return NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: delim})
+		}
+	}
+	return codecModelSourceBuilders[codecName](filename)
+}
+
+// Reads the first few lines of the file.  Returns nil if the file cannot be read.
+func readSniffLines(filename string) []string {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	lines := make([]string, 0, sniffLineCount)
+	scanner := bufio.NewScanner(f)
+	for len(lines) < sniffLineCount && scanner.Scan() {
+		if line := strings.TrimRight(scanner.Text(), "\r"); strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// Determines the codec from the content of the file.  Defaults to CSV.
+func sniffCodec(lines []string) string {
+	if len(lines) == 0 {
+		return "csv"
+	}
+
+	first := strings.TrimSpace(lines[0])
+	if strings.HasPrefix(first, "||") {
+		return "jira"
+	}
+	if strings.HasPrefix(first, "|") && len(lines) >= 2 {
+		if _, isDelimiter := parseMarkdownDelimiterRow(parseMarkdownTableRow(lines[1])); isDelimiter {
+			return "markdown"
+		}
+		return "jira"
+	}
+	return "csv"
+}
+
+// Determines the delimiter of a delimited file.  The delimiter chosen is the one which appears the
+// same number of times on each line, preferring those that appear more often.  If no such delimiter
+// is found, the one with the highest minimum count is used.  Returns false if no delimiter appears
+// on every line.
+func sniffDelimiter(lines []string) (rune, bool) {
+	if len(lines) == 0 {
+		return 0, false
+	}
+
+	var bestDelim rune
+	bestConsistent, bestMinCount := false, 0
+
+	for _, delim := range sniffDelimiters {
+		minCount, maxCount := -1, 0
+		for _, line := range lines {
+			count := countUnquoted(line, delim)
+			if minCount == -1 || count < minCount {
+				minCount = count
+			}
+			if count > maxCount {
+				maxCount = count
+			}
+		}
+
+		if minCount == 0 {
+			continue
+		}
+
+		consistent := minCount == maxCount
+		if (consistent && !bestConsistent) || (consistent == bestConsistent && minCount > bestMinCount) {
+			bestDelim, bestConsistent, bestMinCount = delim, consistent, minCount
+		}
+	}
+
+	return bestDelim, bestMinCount > 0
+}
+
+// Counts the occurrences of a rune that are not within double quotes.
+func countUnquoted(line string, ch rune) int {
+	count := 0
+	inQuotes := false
+	for _, r := range line {
+		if r == '"' {
+			inQuotes = !inQuotes
+		} else if r == ch && !inQuotes {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectModelSource(t *testing.T) {
+	scenarios := []struct {
+		name           string
+		filename       string
+		content        string
+		expectedFormat string
+	}{
+		{name: "csv by extension", filename: "data.csv", content: "a,b,c\n1,2,3\n", expectedFormat: `csv ','`},
+		{name: "csv with semicolons", filename: "data.csv", content: "a;b,x;c\n1;2;3\n", expectedFormat: `csv ';'`},
+		{name: "tsv by extension", filename: "data.tsv", content: "a,b\n", expectedFormat: `tsv '\t'`},
+		{name: "markdown by extension", filename: "README.md", content: "", expectedFormat: "markdown"},
+		{name: "tabs by content", filename: "data.txt", content: "a\tb, c\tc\n1\t2\t3\n", expectedFormat: `tsv '\t'`},
+		{name: "pipes by content", filename: "data.txt", content: "a|b|c\n1|2|3\n", expectedFormat: `csv '|'`},
+		{name: "quoted delimiters by content", filename: "data", content: "\"a;b\",c\n\"1;2\",3\n", expectedFormat: `csv ','`},
+		{name: "markdown by content", filename: "table.txt", content: "| a | b |\n|---|---|\n| 1 | 2 |\n", expectedFormat: "markdown"},
+		{name: "jira by content", filename: "table.txt", content: "|| a || b ||\n| 1 | 2 |\n", expectedFormat: "jira"},
+		{name: "missing file", filename: "new.txt", content: "", expectedFormat: `csv ','`},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), scenario.filename)
+			if scenario.content != "" {
+				filename = writeTestFile(t, scenario.filename, scenario.content)
+			}
+
+			source := detectModelSource(filename)
+			assert.Equal(t, scenario.expectedFormat, source.(DescribableModelSource).Format())
+		})
+	}
+}
@@ -0,0 +1,769 @@
+package main
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lmika/ted/ui"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEditor_EndToEnd(t *testing.T) {
+	t.Run("should display the file", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "letters,numbers\na,1\nb,2\n")
+		editor := newTestEditor(t, NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}))
+
+		editor.run()
+
+		lines := editor.driver.ScreenLines()
+		assert.Contains(t, lines[1], "letters")
+		assert.Contains(t, lines[1], "numbers")
+		assert.Contains(t, lines[2], "a")
+		assert.Contains(t, lines[3], "b")
+		assert.Contains(t, lines[len(lines)-2], "data.csv")
+	})
+
+	t.Run("should edit a cell and save the file", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "letters,numbers\na,1\nb,2\n")
+		editor := newTestEditor(t, NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}))
+
+		editor.driver.PushKeys("klr")
+		editor.driver.PushKeys("one")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		lines := editor.driver.ScreenLines()
+		assert.Contains(t, lines[2], "one")
+		assert.Contains(t, lines[len(lines)-2], "data.csv [+]")
+
+		editor.driver.PushKeys(":w")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		assertFileContent(t, filename, "letters,numbers\na,one\nb,2\n")
+		lines = editor.driver.ScreenLines()
+		assert.NotContains(t, lines[len(lines)-2], "[+]")
+		assert.Contains(t, lines[len(lines)-1], "Wrote data.csv")
+	})
+
+	t.Run("should not quit with unsaved changes", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "letters,numbers\na,1\nb,2\n")
+		editor := newTestEditor(t, NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}))
+
+		editor.driver.PushKeys("D:q")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		lines := editor.driver.ScreenLines()
+		assert.Contains(t, lines[len(lines)-1], "unsaved changes (use q! to force)")
+		assertFileContent(t, filename, "letters,numbers\na,1\nb,2\n")
+	})
+
+	t.Run("should undo changes", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "letters,numbers\na,1\nb,2\n")
+		editor := newTestEditor(t, NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}))
+
+		editor.driver.PushKeys("DDu:w")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		assertFileContent(t, filename, "a,1\nb,2\n")
+	})
+
+	t.Run("should filter rows", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "name,qty\nalice,9\nbob,10\ncarol,100\n")
+		editor := newTestEditor(t, NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}))
+
+		editor.driver.PushKeys(":filter 1 >= 10")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		lines := editor.driver.ScreenLines()
+		assert.Contains(t, lines[1], "name")
+		assert.Contains(t, lines[2], "bob")
+		assert.Contains(t, lines[3], "carol")
+		assert.Contains(t, lines[len(lines)-2], "3 of 4 rows")
+
+		editor.driver.PushKeys(":unfilter")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		lines = editor.driver.ScreenLines()
+		assert.Contains(t, lines[2], "alice")
+		assert.NotContains(t, lines[len(lines)-2], "rows")
+	})
+
+	t.Run("should leave rows hidden by a filter when changing a range", func(t *testing.T) {
+		scenarios := []struct {
+			desc     string
+			keys     string
+			expected string
+		}{
+			{"delete the selected rows", "kVkx", "name,qty\nb,2\nd,4\ne,5\nf,6\n"},
+			{"delete count rows", "k4dd", "name,qty\nb,2\nf,6\n"},
+			{"fill the selected rows", "kVk:fill z\n", "name,qty\nz,z\nb,2\nz,z\nd,4\ne,5\nf,6\n"},
+			{"convert the selected rows to uppercase", "kVk:to-upper\n", "name,qty\nA,1\nb,2\nC,3\nd,4\ne,5\nf,6\n"},
+			{"yank the selected rows", "kVkykp", "name,qty\na,1\nb,2\nc,3\na,1\nc,3\nf,6\n"},
+			{"paste over the hidden rows", "kkkVkyiiip", "name,qty\nd,4\nb,2\ne,5\nd,4\ne,5\nf,6\n"},
+		}
+
+		for _, scenario := range scenarios {
+			t.Run(scenario.desc, func(t *testing.T) {
+				filename := writeTestFile(t, "data.csv", "name,qty\na,1\nb,2\nc,3\nd,4\ne,5\nf,6\n")
+				editor := newTestEditorWithSettings(t, NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}), map[string]string{"header": "1"})
+
+				editor.driver.PushKeys(":filter name != b")
+				editor.driver.PushKey(ui.KeyEnter, 0)
+				for _, part := range strings.SplitAfter(scenario.keys, "\n") {
+					editor.driver.PushKeys(strings.TrimSuffix(part, "\n"))
+					if strings.HasSuffix(part, "\n") {
+						editor.driver.PushKey(ui.KeyEnter, 0)
+					}
+				}
+				editor.driver.PushKeys(":w")
+				editor.driver.PushKey(ui.KeyEnter, 0)
+				editor.run()
+
+				assertFileContent(t, filename, scenario.expected)
+			})
+		}
+	})
+
+	t.Run("should run a command for each visible row", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "a\nb\nc\nd\ne\n")
+		editor := newTestEditor(t, NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}))
+
+		editor.driver.PushKeys(`:filter 0 ~ "^[ad]$"`)
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.driver.PushKeys(`:each-row pipe "sed s/$/x/"`)
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.driver.PushKeys(":w")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		assertFileContent(t, filename, "ax\nb\nc\ndx\ne\n")
+	})
+
+	t.Run("should use the header row", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "name,qty\nalice,9\nbob,10\ncarol,100\n")
+		editor := newTestEditor(t, NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}))
+
+		editor.driver.PushKeys(":set header 1")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.driver.PushKeys(":sort qty:desc")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		lines := editor.driver.ScreenLines()
+		assert.Regexp(t, `^\s+name\s+qty`, lines[0])
+		assert.Regexp(t, `^0\s+name\s+qty`, lines[1])
+		assert.Regexp(t, `^1\s+carol\s+100`, lines[2])
+		assert.Regexp(t, `^2\s+bob\s+10`, lines[3])
+		assert.Regexp(t, `^3\s+alice\s+9`, lines[4])
+	})
+
+	t.Run("should fit the column widths on load", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "name,qty\nalice,9\nbob,10\n")
+		source := NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','})
+		editor := newTestEditorWithSettings(t, source, map[string]string{"fit-on-load": "true"})
+
+		editor.run()
+
+		lines := editor.driver.ScreenLines()
+		assert.Regexp(t, `^0       name  qty ~`, lines[1])
+		assert.Regexp(t, `^1       alice 9   ~`, lines[2])
+	})
+
+	t.Run("should clear the cells selected in visual mode", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "a,b,c\nd,e,f\ng,h,i\n")
+		editor := newTestEditor(t, NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}))
+
+		editor.driver.PushKeys("lvkl")
+		editor.run()
+
+		lines := editor.driver.ScreenLines()
+		assert.Contains(t, lines[len(lines)-2], "VISUAL")
+		assert.Equal(t, CellRange{Row1: 0, Col1: 1, Row2: 1, Col2: 2}, editor.session.Frame.SelectedRange())
+
+		editor.driver.PushKeys("x:w")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		lines = editor.driver.ScreenLines()
+		assert.NotContains(t, lines[len(lines)-2], "VISUAL")
+		assertFileContent(t, filename, "a,,\nd,,\ng,h,i\n")
+	})
+
+	t.Run("should operate on the current cell once visual mode is left", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "a,b\nc,d\n")
+		editor := newTestEditor(t, NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}))
+
+		editor.driver.PushKeys("vkl")
+		editor.driver.PushKey(ui.KeyEsc, 0)
+		editor.driver.PushKeys("x:w")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		assertFileContent(t, filename, "a,b\nc,\n")
+	})
+
+	t.Run("should delete the rows or columns selected in visual mode", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "a,b,c\nd,e,f\ng,h,i\n")
+		editor := newTestEditor(t, NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}))
+
+		editor.driver.PushKeys("Vkx:w")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		assertFileContent(t, filename, "g,h,i\n")
+
+		editor.driver.PushKey(ui.KeyCtrlV, 0)
+		editor.driver.PushKeys("lx:w")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		assertFileContent(t, filename, "i\n")
+	})
+
+	t.Run("should undo a change to the selected range as one change", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "a,b\nc,d\ne,f\n")
+		editor := newTestEditor(t, NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}))
+
+		editor.driver.PushKeys("vklyjkp:w")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		assertFileContent(t, filename, "a,b\nc,d\na,b\nc,d\n")
+
+		editor.driver.PushKeys("u:w")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		assertFileContent(t, filename, "a,b\nc,d\ne,f\n")
+
+		editor.driver.PushKeys("ggVkx:w")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		assertFileContent(t, filename, "e,f\n")
+
+		editor.driver.PushKeys("u:w")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		assertFileContent(t, filename, "a,b\nc,d\ne,f\n")
+	})
+
+	t.Run("should move and delete by a count", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "a\nb\nc\nd\ne\nf\n")
+		editor := newTestEditor(t, NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}))
+
+		editor.driver.PushKeys("3k")
+		editor.run()
+
+		_, cellY := editor.session.Frame.Grid().CellPosition()
+		assert.Equal(t, 3, cellY)
+
+		editor.driver.PushKeys("2iG10dd:w")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		assertFileContent(t, filename, "a\nb\nc\nd\ne\n")
+		assert.Contains(t, editor.driver.ScreenLines()[len(editor.driver.ScreenLines())-1], "Wrote")
+
+		editor.driver.PushKeys("ggkdd:w")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		assertFileContent(t, filename, "a\nc\nd\ne\n")
+	})
+
+	t.Run("should delete rows by a count starting with one", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n")
+		editor := newTestEditor(t, NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}))
+
+		editor.driver.PushKeys("10D:w")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		assertFileContent(t, filename, "11\n12\n13\n")
+	})
+
+	t.Run("should mark rows with the marker keys", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "a\nb\nc\nd\ne\n")
+		editor := newTestEditor(t, NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}))
+
+		editor.driver.PushKeys("m1km2km3km1m0")
+		editor.run()
+
+		assert.Equal(t, MarkerRed, editor.session.modelController.RowAttrs(0).Marker)
+		assert.Equal(t, MarkerGreen, editor.session.modelController.RowAttrs(1).Marker)
+		assert.Equal(t, MarkerBlue, editor.session.modelController.RowAttrs(2).Marker)
+		assert.Equal(t, MarkerNone, editor.session.modelController.RowAttrs(3).Marker)
+	})
+
+	t.Run("should discard unmapped key sequences", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "a\nb\nc\n")
+		editor := newTestEditor(t, NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}))
+
+		editor.driver.PushKeys("kgxk")
+		editor.run()
+
+		_, cellY := editor.session.Frame.Grid().CellPosition()
+		assert.Equal(t, 2, cellY)
+	})
+
+	t.Run("should invoke the shorter mapping once the key timeout expires", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "a\nb\nc\n")
+		rcFilename := writeTestFile(t, "tedrc", "set key-timeout 1\nmap g move-down\n")
+		editor := newTestEditor(t, NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}))
+		assert.NoError(t, editor.session.LoadConfig(rcFilename))
+
+		editor.driver.PushKeys("g")
+		editor.run()
+
+		_, cellY := editor.session.Frame.Grid().CellPosition()
+		assert.Equal(t, 0, cellY)
+
+		assert.Eventually(t, func() bool {
+			editor.run()
+			_, cellY := editor.session.Frame.Grid().CellPosition()
+			return cellY == 1
+		}, time.Second, 5*time.Millisecond)
+
+		editor.driver.PushKeys("gg")
+		editor.run()
+
+		_, cellY = editor.session.Frame.Grid().CellPosition()
+		assert.Equal(t, 0, cellY)
+	})
+
+	t.Run("should update the selection and status bar once the key timeout expires", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "a\nb\nc\n")
+		rcFilename := writeTestFile(t, "tedrc", "set key-timeout 1\nmap g move-down\nmap s clear\nmap sx delete-row\n")
+		editor := newTestEditor(t, NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}))
+		assert.NoError(t, editor.session.LoadConfig(rcFilename))
+
+		editor.driver.PushKeys("Vg")
+		editor.run()
+
+		assert.Eventually(t, func() bool {
+			editor.run()
+			return editor.session.Frame.SelectedRange().Row2 == 1
+		}, time.Second, 5*time.Millisecond)
+
+		editor.driver.PushKey(ui.KeyEsc, 0)
+		editor.driver.PushKeys("s")
+		editor.run()
+
+		assert.Eventually(t, func() bool {
+			editor.run()
+			lines := editor.driver.ScreenLines()
+			return strings.Contains(lines[len(lines)-2], "[+]")
+		}, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("should recall and complete commands", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "name,qty\nalice,9\nbob,10\n")
+		editor := newTestEditor(t, NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}))
+
+		editor.driver.PushKeys(":sort-desc 1")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.driver.PushKeys(":so")
+		editor.driver.PushKey(ui.KeyCtrlI, 0)
+		editor.run()
+
+		lines := editor.driver.ScreenLines()
+		assert.Regexp(t, `^sort\s+sort-desc`, lines[len(lines)-3])
+		assert.Equal(t, ":sort", lines[len(lines)-1])
+
+		editor.driver.PushKey(ui.KeyArrowUp, 0)
+		editor.run()
+
+		lines = editor.driver.ScreenLines()
+		assert.NotContains(t, lines[len(lines)-3], "sort-desc")
+		assert.Equal(t, ":sort-desc 1", lines[len(lines)-1])
+
+		editor.driver.PushKey(ui.KeyBackspace2, 0)
+		editor.driver.PushKeys("0")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		assert.Equal(t, []string{"sort-desc 1", "sort-desc 0"}, editor.session.CommandHistory.Entries)
+		assert.Regexp(t, `^0\s+name\s+qty`, editor.driver.ScreenLines()[1])
+	})
+
+	t.Run("should remap keys from the config file", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "name,qty\nalice,9\nbob,10\n")
+		rcFilename := writeTestFile(t, "tedrc", strings.Join([]string{
+			`" Vim style movement`,
+			"map h move-left",
+			"map j move-down",
+			"map k move-up",
+			"map l move-right",
+			"unmap i",
+			"",
+			"# Sorting",
+			"alias sq sort-desc 1",
+			"map S sq",
+		}, "\n"))
+		editor := newTestEditor(t, NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}))
+		assert.NoError(t, editor.session.LoadConfig(rcFilename))
+
+		editor.driver.PushKeys("jjlkiS")
+		editor.run()
+
+		cellX, cellY := editor.session.Frame.Grid().CellPosition()
+		assert.Equal(t, 1, cellX)
+		assert.Equal(t, 1, cellY)
+
+		lines := editor.driver.ScreenLines()
+		assert.Regexp(t, `^0\s+name\s+qty`, lines[1])
+		assert.Regexp(t, `^1\s+bob\s+10`, lines[2])
+		assert.Regexp(t, `^2\s+alice\s+9`, lines[3])
+	})
+
+	t.Run("should map keys to command expressions", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "name,qty\nalice,9\nbob,10\n")
+		rcFilename := writeTestFile(t, "tedrc", strings.Join([]string{
+			`map E edit-cell "hello world"`,
+			`map U "each-row to-upper; move-right"`,
+		}, "\n"))
+		editor := newTestEditor(t, NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}))
+		assert.NoError(t, editor.session.LoadConfig(rcFilename))
+
+		editor.driver.PushKeys("UkE:w")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		assertFileContent(t, filename, "NAME,qty\nALICE,hello world\nBOB,10\n")
+	})
+
+	t.Run("should show and close the help panel", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "letters,numbers\na,1\nb,2\n")
+		editor := newTestEditor(t, NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}))
+
+		editor.driver.PushKeys(":help w")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		screen := strings.Join(editor.driver.ScreenLines(), "\n")
+		assert.Contains(t, screen, "Help: w")
+		assert.Contains(t, screen, "save (w)")
+		assert.Contains(t, screen, "Save current file")
+
+		editor.driver.PushKeys("q?")
+		editor.run()
+
+		screen = strings.Join(editor.driver.ScreenLines(), "\n")
+		assert.NotContains(t, screen, "Help: w")
+		assert.Contains(t, screen, "Help")
+		assert.Contains(t, screen, "clear")
+
+		editor.driver.PushKey(ui.KeyEsc, 0)
+		editor.driver.PushKeys("D")
+		editor.run()
+
+		lines := editor.driver.ScreenLines()
+		assert.NotContains(t, strings.Join(lines, "\n"), "Help")
+		assert.Contains(t, lines[len(lines)-2], "data.csv [+]")
+	})
+
+	t.Run("should report help of an unknown command", func(t *testing.T) {
+		editor := newTestEditor(t, NewCsvFileModelSource(writeTestFile(t, "data.csv", "a\n"), CsvFileModelSourceOptions{Comma: ','}))
+
+		editor.driver.PushKeys(":help nope")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		lines := editor.driver.ScreenLines()
+		assert.Contains(t, lines[len(lines)-1], "no such command: nope")
+	})
+
+	t.Run("should pipe the current cell through a shell command", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "name,qty\nalice,3\nbob,1\n")
+		editor := newTestEditor(t, NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}))
+
+		editor.driver.PushKeys("k:!tr a-z A-Z")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.driver.PushKeys(":w")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		assertFileContent(t, filename, "name,qty\nALICE,3\nbob,1\n")
+	})
+
+	t.Run("should pipe the column below the header and undo it as one change", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "name,qty\nalice,3\nbob,1\ncarol,2\n")
+		editor := newTestEditorWithSettings(t, NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}), map[string]string{"header": "1"})
+
+		editor.driver.PushKeys("l:pipe-col sort -n")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.driver.PushKeys(":w")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		assertFileContent(t, filename, "name,qty\nalice,1\nbob,2\ncarol,3\n")
+
+		editor.driver.PushKeys("u:w")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		assertFileContent(t, filename, "name,qty\nalice,3\nbob,1\ncarol,2\n")
+	})
+
+	t.Run("should pipe the whole model and resize it to the output", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "name,qty\nalice,3\nbob,1\ncarol,2\n")
+		editor := newTestEditorWithSettings(t, NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}), map[string]string{"header": "1"})
+
+		editor.driver.PushKeys(`:pipe-all awk '$2 > 1 { print $0 "\t" $2 * 2 }'`)
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.driver.PushKeys(":w")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		assertFileContent(t, filename, "name,qty,\nalice,3,6\ncarol,2,4\n")
+	})
+
+	t.Run("should pipe the selection as CSV when prompted with the ! key", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "b,2\na,1\nc,3\n")
+		editor := newTestEditorWithSettings(t, NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}), map[string]string{"pipe-codec": "csv"})
+
+		editor.driver.PushKeys("Vk!sort -t, -k1")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.driver.PushKeys(":w")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		assertFileContent(t, filename, "a,1\nb,2\nc,3\n")
+	})
+
+	t.Run("should leave out rows hidden by a filter when piping a column", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "name,qty\nalice,3\nbob,9\ncarol,1\ndave,2\n")
+		editor := newTestEditorWithSettings(t, NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}), map[string]string{"header": "1"})
+
+		editor.driver.PushKeys(":filter name != bob")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.driver.PushKeys("l:pipe-col sort -n")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.driver.PushKeys(":w")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		assertFileContent(t, filename, "name,qty\nalice,1\nbob,9\ncarol,2\ndave,3\n")
+	})
+
+	t.Run("should not pipe the whole model while rows are hidden by a filter", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "name,qty\nalice,3\nbob,9\n")
+		editor := newTestEditorWithSettings(t, NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}), map[string]string{"header": "1"})
+
+		editor.driver.PushKeys(":filter name != bob")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.driver.PushKeys(":pipe-all sort")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		lines := editor.driver.ScreenLines()
+		assert.Contains(t, lines[len(lines)-1], "Cannot pipe all rows while rows are hidden")
+	})
+
+	t.Run("should report commands which fail without changing the cells", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "a,1\nb,2\n")
+		editor := newTestEditor(t, NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}))
+
+		editor.driver.PushKeys(`:! "echo oops >&2; exit 1"`)
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		lines := editor.driver.ScreenLines()
+		assert.Contains(t, lines[len(lines)-1], "oops (exit status 1)")
+		assert.Contains(t, lines[1], "a")
+		assert.NotContains(t, lines[len(lines)-2], "[+]")
+	})
+
+	t.Run("should set the column of visible rows to an expression", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "name,price,qty,total\napple,1.5,4,\nkiwi,0.25,3,\npear,2,1,\n")
+		editor := newTestEditorWithSettings(t, NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}), map[string]string{"header": "1"})
+
+		editor.driver.PushKeys(":filter name !~ kiwi")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.driver.PushKeys("lll:set-col '$price * $qty'")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.driver.PushKeys(":w")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		assertFileContent(t, filename, "name,price,qty,total\napple,1.5,4,6\nkiwi,0.25,3,\npear,2,1,2\n")
+	})
+
+	t.Run("should map the selected cells with an expression entered at the prompt", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "a-1,x\nb-2,y\nc-3,z\n")
+		editor := newTestEditor(t, NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}))
+
+		editor.driver.PushKeys("vk:map-col")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.driver.PushKeys(`upper(match(value, "(\w)-")) & row`)
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.driver.PushKeys(":w")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		assertFileContent(t, filename, "A0,x\nB1,y\nc-3,z\n")
+
+		editor.driver.PushKeys("u:w")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		assertFileContent(t, filename, "a-1,x\nb-2,y\nc-3,z\n")
+	})
+
+	t.Run("should report the cell an expression fails at", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "1\n2\nthree\n")
+		editor := newTestEditor(t, NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}))
+
+		editor.driver.PushKeys(":set-col 'value * 2'")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		lines := editor.driver.ScreenLines()
+		assert.Contains(t, lines[len(lines)-1], "at [0, 2]: not a number: 'three'")
+		assert.Contains(t, lines[1], "1")
+	})
+
+	t.Run("should show the stats of the current column", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "name,qty\napple,3\nkiwi,\npear,1.5\nfig,3\n")
+		editor := newTestEditorWithSettings(t, NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}), map[string]string{"header": "1"})
+
+		editor.driver.PushKeys("l:stats")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		screen := strings.Join(editor.driver.ScreenLines(), "\n")
+		assert.Contains(t, screen, "Stats: qty")
+		assert.Contains(t, screen, "Count     4")
+		assert.Contains(t, screen, "Blanks    1")
+		assert.Contains(t, screen, "1-6 of 13")
+
+		editor.driver.PushKey(ui.KeyEnd, 0)
+		editor.run()
+
+		screen = strings.Join(editor.driver.ScreenLines(), "\n")
+		assert.Contains(t, screen, "Mean      2.5")
+		assert.Contains(t, screen, "Median    3")
+		assert.Contains(t, screen, "  2  3")
+	})
+
+	t.Run("should compute the stats of large models in the background", func(t *testing.T) {
+		var sb strings.Builder
+		for r := 0; r <= backgroundStatsValues; r++ {
+			sb.WriteString(strconv.Itoa(r%10) + "\n")
+		}
+		filename := writeTestFile(t, "data.csv", sb.String())
+		editor := newTestEditor(t, NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}))
+
+		editor.driver.PushKeys(":stats")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		assert.Eventually(t, func() bool {
+			editor.run()
+			return strings.Contains(strings.Join(editor.driver.ScreenLines(), "\n"), "Count     50001")
+		}, 5*time.Second, 10*time.Millisecond)
+
+		editor.driver.PushKeys(" ")
+		editor.run()
+
+		screen := strings.Join(editor.driver.ScreenLines(), "\n")
+		assert.Contains(t, screen, "Mean      4.49991000179996")
+		assert.Contains(t, screen, "  5001  0")
+	})
+
+	t.Run("should save the view state on quit unless forced", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "a\nb\n")
+		statePath, err := viewStatePath(filename, ViewStateSidecar)
+		assert.NoError(t, err)
+
+		editor := newTestEditorWithSettings(t, NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}), map[string]string{"view-state": "sidecar"})
+		editor.driver.PushKeys("m1:q!")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		assert.NoError(t, editor.session.SaveViewStateOnQuit())
+		assert.NoFileExists(t, statePath)
+
+		editor = newTestEditorWithSettings(t, NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}), map[string]string{"view-state": "sidecar"})
+		editor.driver.PushKeys("m1:q")
+		editor.driver.PushKey(ui.KeyEnter, 0)
+		editor.run()
+
+		assert.NoError(t, editor.session.SaveViewStateOnQuit())
+		assert.FileExists(t, statePath)
+	})
+
+	t.Run("should prefer settings given as flags over the view state", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "a\nb\nc\n")
+		statePath, err := viewStatePath(filename, ViewStateSidecar)
+		assert.NoError(t, err)
+		assert.NoError(t, writeViewState(statePath, &viewState{HeaderRows: 2}))
+
+		editor := newTestEditorWithSettings(t, NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}), map[string]string{"view-state": "sidecar", "header": "1"})
+
+		headerRows, _ := editor.session.modelController.Header()
+		assert.Equal(t, 1, headerRows)
+	})
+
+	t.Run("should report the first failing line of the config file", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "name,qty\n")
+		rcFilename := writeTestFile(t, "tedrc", "map h move-left\nmap x no-such-command\nset no-such-setting 1\nmap z move-right\n")
+		editor := newTestEditor(t, NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}))
+
+		err := editor.session.LoadConfig(rcFilename)
+
+		assert.EqualError(t, err, "tedrc:2: no such command: no-such-command")
+		assert.NotNil(t, editor.session.Commands.KeyMapping('z'))
+	})
+
+	t.Run("should ignore a missing config file", func(t *testing.T) {
+		editor := newTestEditor(t, NewCsvFileModelSource(writeTestFile(t, "data.csv", "a\n"), CsvFileModelSourceOptions{Comma: ','}))
+		assert.NoError(t, editor.session.LoadConfig(filepath.Join(t.TempDir(), "tedrc")))
+	})
+}
+
+// An editor running within a headless UI
+type testEditor struct {
+	driver  *ui.HeadlessDriver
+	ui      *ui.Ui
+	session *Session
+}
+
+func newTestEditor(t *testing.T, source ModelSource) *testEditor {
+	return newTestEditorWithSettings(t, source, nil)
+}
+
+// Creates a test editor with settings changed before the source is loaded, as they would be by flags
+func newTestEditorWithSettings(t *testing.T, source ModelSource, settings map[string]string) *testEditor {
+	driver := ui.NewHeadlessDriver(80, 12)
+	uiManager, err := ui.NewUIWithDriver(driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frame := NewFrame(uiManager)
+	session := NewSession(uiManager, frame, source)
+	if err := session.LoadFromSourceWithSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+
+	uiManager.SetRootComponent(frame.RootComponent())
+	frame.setMode(GridMode)
+
+	return &testEditor{driver: driver, ui: uiManager, session: session}
+}
+
+// Processes the queued key presses and renders the screen
+func (te *testEditor) run() {
+	te.ui.Loop()
+}
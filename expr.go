@@ -0,0 +1,628 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// An expression which computes the value of a cell, as used by set-col.  Values are strings, which are
+// treated as numbers by arithmetic operators and numeric functions.  Expressions can contain:
+//
+//	$2, $qty, ${unit price}   the value of a column of the same row, by index or header name
+//	value                     the value of the cell being computed
+//	row                       the index of the row being computed
+//	12.5, "text", 'text'      numbers and strings
+//	+ - * / %                 arithmetic, where blank values are taken to be 0
+//	&                         concatenation
+//	= == != < <= > >=         comparison, with numbers and dates compared by value, as when sorting
+//	=~ !~                     matching a regular expression
+//	&& || !                   logic, where "", "false" and 0 are false
+//	upper(s) ...              functions, which are listed in exprFunctions
+type cellExpr func(env *exprEnv) (string, error)
+
+// The cell an expression is evaluated for
+type exprEnv struct {
+	model Model
+	row   int
+	col   int
+}
+
+// Evaluates the expression for the cell at the row and column of the model
+func (expr cellExpr) Eval(model Model, row, col int) (string, error) {
+	return expr(&exprEnv{model: model, row: row, col: col})
+}
+
+// Parses an expression.  Columns referred to by the expression are resolved using columnIndex.
+func parseCellExpr(text string, columnIndex func(ref string) (int, error)) (cellExpr, error) {
+	tokens, err := scanExprTokens(text)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &exprParser{tokens: tokens, columnIndex: columnIndex}
+	expr, err := p.parseBinary(1)
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != exprEOF {
+		return nil, fmt.Errorf("unexpected '%v' at %d", tok.text, tok.pos+1)
+	}
+	return expr, nil
+}
+
+type exprTokenKind int
+
+const (
+	exprEOF exprTokenKind = iota
+	exprNumber
+	exprString
+	exprIdent
+	exprColumn
+	exprOperator
+)
+
+type exprToken struct {
+	kind exprTokenKind
+	text string
+
+	// The byte offset of the token within the expression
+	pos int
+}
+
+// The operators of expressions, longest first so that they are scanned in preference to their prefixes
+var exprOperators = []string{"==", "!=", "<=", ">=", "=~", "!~", "&&", "||", "+", "-", "*", "/", "%", "&", "=", "<", ">", "!", "(", ")", ","}
+
+// Splits an expression into tokens
+func scanExprTokens(text string) ([]exprToken, error) {
+	tokens := make([]exprToken, 0)
+	for pos := 0; pos < len(text); {
+		r, w := utf8.DecodeRuneInString(text[pos:])
+		start := pos
+
+		switch {
+		case unicode.IsSpace(r):
+			pos += w
+			continue
+		case isDigit(r) || (r == '.' && pos+1 < len(text) && isDigit(rune(text[pos+1]))):
+			pos += strings.IndexFunc(text[pos:]+" ", func(r rune) bool { return !isDigit(r) && r != '.' })
+			tokens = append(tokens, exprToken{exprNumber, text[start:pos], start})
+		case isExprIdentRune(r):
+			pos += strings.IndexFunc(text[pos:]+" ", func(r rune) bool { return !isExprIdentRune(r) && !isDigit(r) })
+			tokens = append(tokens, exprToken{exprIdent, text[start:pos], start})
+		case r == '"' || r == '\'':
+			value, n, err := scanExprString(text[pos:])
+			if err != nil {
+				return nil, fmt.Errorf("%v at %d", err, start+1)
+			}
+			pos += n
+			tokens = append(tokens, exprToken{exprString, value, start})
+		case r == '$':
+			ref, n, err := scanExprColumn(text[pos:])
+			if err != nil {
+				return nil, fmt.Errorf("%v at %d", err, start+1)
+			}
+			pos += n
+			tokens = append(tokens, exprToken{exprColumn, ref, start})
+		default:
+			op := ""
+			for _, candidate := range exprOperators {
+				if strings.HasPrefix(text[pos:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected '%c' at %d", r, start+1)
+			}
+			pos += len(op)
+			tokens = append(tokens, exprToken{exprOperator, op, start})
+		}
+	}
+	return append(tokens, exprToken{exprEOF, "end of expression", len(text)}), nil
+}
+
+func isExprIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+// Scans a string quoted with single or double quotes.  Within the string, a backslash escapes a quote or
+// another backslash, while other backslashes are kept so that regular expressions can be written as they
+// are.  Returns the string and the length of the quoted text.
+func scanExprString(text string) (string, int, error) {
+	quote := text[0]
+	var sb strings.Builder
+	for i := 1; i < len(text); i++ {
+		switch c := text[i]; {
+		case c == quote:
+			return sb.String(), i + 1, nil
+		case c == '\\' && i+1 < len(text) && (text[i+1] == quote || text[i+1] == '\\'):
+			i++
+			sb.WriteByte(text[i])
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", 0, errors.New("unterminated string")
+}
+
+// Scans a column reference, which is a "$" followed by a column index or name.  Names containing other
+// characters than letters, digits and underscores are placed within braces, as in ${unit price}.
+// Returns the index or name and the length of the reference.
+func scanExprColumn(text string) (string, int, error) {
+	if strings.HasPrefix(text, "${") {
+		end := strings.IndexByte(text, '}')
+		if end < 0 {
+			return "", 0, errors.New("unterminated column name")
+		}
+		return text[2:end], end + 1, nil
+	}
+
+	end := 1 + strings.IndexFunc(text[1:]+" ", func(r rune) bool { return !isExprIdentRune(r) && !isDigit(r) })
+	if end == 1 {
+		return "", 0, errors.New("column index or name expected after '$'")
+	}
+	return text[1:end], end, nil
+}
+
+type exprParser struct {
+	tokens      []exprToken
+	pos         int
+	columnIndex func(ref string) (int, error)
+}
+
+// The precedence of binary operators.  Operators with a higher precedence bind more tightly.
+var exprBinaryPrecedence = map[string]int{
+	"||": 1,
+	"&&": 2,
+	"=":  3, "==": 3, "!=": 3, "<": 3, "<=": 3, ">": 3, ">=": 3, "=~": 3, "!~": 3,
+	"&": 4,
+	"+": 5, "-": 5,
+	"*": 6, "/": 6, "%": 6,
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() exprToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != exprEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// Consumes the next token if it is the operator, returning whether it was
+func (p *exprParser) accept(op string) bool {
+	if tok := p.peek(); tok.kind == exprOperator && tok.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) expect(op string) error {
+	if !p.accept(op) {
+		tok := p.peek()
+		return fmt.Errorf("expected '%v' but was '%v' at %d", op, tok.text, tok.pos+1)
+	}
+	return nil
+}
+
+// Parses binary operations of operators with at least the precedence
+func (p *exprParser) parseBinary(minPrecedence int) (cellExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		tok := p.peek()
+		precedence, isBinary := exprBinaryPrecedence[tok.text]
+		if tok.kind != exprOperator || !isBinary || precedence < minPrecedence {
+			return left, nil
+		}
+		p.next()
+
+		right, err := p.parseBinary(precedence + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr(tok.text, left, right)
+	}
+}
+
+func (p *exprParser) parseUnary() (cellExpr, error) {
+	switch {
+	case p.accept("-"):
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return numericExpr(func(args []float64) (float64, error) { return -args[0], nil }, operand), nil
+	case p.accept("!"):
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(env *exprEnv) (string, error) {
+			value, err := operand(env)
+			return formatExprBool(!isExprTrue(value)), err
+		}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (cellExpr, error) {
+	tok := p.next()
+	switch tok.kind {
+	case exprNumber:
+		if _, err := strconv.ParseFloat(tok.text, 64); err != nil {
+			return nil, fmt.Errorf("invalid number '%v' at %d", tok.text, tok.pos+1)
+		}
+		return literalExpr(tok.text), nil
+	case exprString:
+		return literalExpr(tok.text), nil
+	case exprColumn:
+		col, err := p.columnIndex(tok.text)
+		if err != nil {
+			return nil, err
+		}
+		return func(env *exprEnv) (string, error) { return env.model.CellValue(env.row, col), nil }, nil
+	case exprIdent:
+		if p.accept("(") {
+			return p.parseCall(tok)
+		} else if variable, isVariable := exprVariables[tok.text]; isVariable {
+			return variable, nil
+		}
+		return nil, fmt.Errorf("unknown variable '%v' at %d", tok.text, tok.pos+1)
+	case exprOperator:
+		if tok.text == "(" {
+			expr, err := p.parseBinary(1)
+			if err != nil {
+				return nil, err
+			}
+			return expr, p.expect(")")
+		}
+	}
+	return nil, fmt.Errorf("unexpected '%v' at %d", tok.text, tok.pos+1)
+}
+
+// Parses the arguments of a function call, following the opening parenthesis
+func (p *exprParser) parseCall(name exprToken) (cellExpr, error) {
+	args := make([]cellExpr, 0)
+	for !p.accept(")") {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+
+		arg, err := p.parseBinary(1)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+
+	if name.text == "if" {
+		return ifExpr(args)
+	}
+
+	fn, isFunction := exprFunctions[name.text]
+	if !isFunction {
+		return nil, fmt.Errorf("unknown function '%v' at %d", name.text, name.pos+1)
+	} else if len(args) < fn.minArgs || (fn.maxArgs >= 0 && len(args) > fn.maxArgs) {
+		return nil, fmt.Errorf("wrong number of arguments to %v at %d", name.text, name.pos+1)
+	}
+
+	return func(env *exprEnv) (string, error) {
+		values, err := evalExprArgs(env, args)
+		if err != nil {
+			return "", err
+		}
+		value, err := fn.fn(values)
+		if err != nil {
+			return "", fmt.Errorf("%v: %v", name.text, err)
+		}
+		return value, nil
+	}, nil
+}
+
+// The variables of expressions
+var exprVariables = map[string]cellExpr{
+	"value": func(env *exprEnv) (string, error) { return env.model.CellValue(env.row, env.col), nil },
+	"row":   func(env *exprEnv) (string, error) { return strconv.Itoa(env.row), nil },
+	"true":  literalExpr("true"),
+	"false": literalExpr("false"),
+}
+
+// A function of expressions, which takes between minArgs and maxArgs arguments.  A maxArgs of -1 means
+// there is no limit.
+type exprFunction struct {
+	minArgs, maxArgs int
+	fn               func(args []string) (string, error)
+}
+
+// The functions of expressions, apart from if(COND, THEN[, ELSE]), which only evaluates one of THEN or ELSE
+var exprFunctions = map[string]exprFunction{
+	"upper": {1, 1, func(args []string) (string, error) { return strings.ToUpper(args[0]), nil }},
+	"lower": {1, 1, func(args []string) (string, error) { return strings.ToLower(args[0]), nil }},
+	"trim":  {1, 1, func(args []string) (string, error) { return strings.TrimSpace(args[0]), nil }},
+	"len":   {1, 1, func(args []string) (string, error) { return strconv.Itoa(utf8.RuneCountInString(args[0])), nil }},
+
+	// substr(S, START[, LENGTH]) returns the characters of S from START, counting from 0
+	"substr": {2, 3, func(args []string) (string, error) {
+		nums, err := parseExprNumbers(args[1:])
+		if err != nil {
+			return "", err
+		}
+
+		runes := []rune(args[0])
+		start := maxInt(minInt(int(nums[0]), len(runes)), 0)
+		end := len(runes)
+		if len(nums) > 1 {
+			end = maxInt(minInt(start+int(nums[1]), len(runes)), start)
+		}
+		return string(runes[start:end]), nil
+	}},
+
+	// replace(S, OLD, NEW) replaces all occurrences of OLD within S with NEW
+	"replace": {3, 3, func(args []string) (string, error) {
+		return strings.ReplaceAll(args[0], args[1], args[2]), nil
+	}},
+
+	// sub(S, REGEX, REPL) replaces all matches of REGEX within S with REPL, which can refer to the
+	// groups of the match as $1, $2 and so on
+	"sub": {3, 3, func(args []string) (string, error) {
+		re, err := compileExprRegexp(args[1])
+		if err != nil {
+			return "", err
+		}
+		return re.ReplaceAllString(args[0], args[2]), nil
+	}},
+
+	// match(S, REGEX[, GROUP]) returns a group of the first match of REGEX within S, or "" if there is
+	// none.  Without GROUP, this is the first group if REGEX has any, otherwise the whole match.
+	"match": {2, 3, func(args []string) (string, error) {
+		re, err := compileExprRegexp(args[1])
+		if err != nil {
+			return "", err
+		}
+
+		group := minInt(re.NumSubexp(), 1)
+		if len(args) > 2 {
+			nums, err := parseExprNumbers(args[2:])
+			if err != nil {
+				return "", err
+			}
+			if group = int(nums[0]); group < 0 || group > re.NumSubexp() {
+				return "", fmt.Errorf("no group %d", group)
+			}
+		}
+
+		if m := re.FindStringSubmatch(args[0]); m != nil {
+			return m[group], nil
+		}
+		return "", nil
+	}},
+
+	"abs":   numericFunction(1, 1, func(nums []float64) float64 { return math.Abs(nums[0]) }),
+	"floor": numericFunction(1, 1, func(nums []float64) float64 { return math.Floor(nums[0]) }),
+	"ceil":  numericFunction(1, 1, func(nums []float64) float64 { return math.Ceil(nums[0]) }),
+
+	// round(N[, PLACES]) rounds N to a number of decimal places, which is 0 if not given
+	"round": numericFunction(1, 2, func(nums []float64) float64 {
+		scale := 1.0
+		if len(nums) > 1 {
+			scale = math.Pow(10, math.Trunc(nums[1]))
+		}
+		return math.Round(nums[0]*scale) / scale
+	}),
+
+	"min": numericFunction(1, -1, func(nums []float64) float64 {
+		min := nums[0]
+		for _, n := range nums[1:] {
+			min = math.Min(min, n)
+		}
+		return min
+	}),
+	"max": numericFunction(1, -1, func(nums []float64) float64 {
+		max := nums[0]
+		for _, n := range nums[1:] {
+			max = math.Max(max, n)
+		}
+		return max
+	}),
+}
+
+// Returns a function of expressions which takes numeric arguments
+func numericFunction(minArgs, maxArgs int, fn func(nums []float64) float64) exprFunction {
+	return exprFunction{minArgs, maxArgs, func(args []string) (string, error) {
+		nums, err := parseExprNumbers(args)
+		if err != nil {
+			return "", err
+		}
+		return formatExprNumber(fn(nums)), nil
+	}}
+}
+
+func literalExpr(value string) cellExpr {
+	return func(env *exprEnv) (string, error) { return value, nil }
+}
+
+func binaryExpr(op string, left, right cellExpr) cellExpr {
+	switch op {
+	case "&&", "||":
+		return func(env *exprEnv) (string, error) {
+			value, err := left(env)
+			if err != nil || isExprTrue(value) == (op == "||") {
+				return formatExprBool(isExprTrue(value)), err
+			}
+			value, err = right(env)
+			return formatExprBool(isExprTrue(value)), err
+		}
+	case "&":
+		return func(env *exprEnv) (string, error) {
+			values, err := evalExprArgs(env, []cellExpr{left, right})
+			if err != nil {
+				return "", err
+			}
+			return values[0] + values[1], nil
+		}
+	case "=~", "!~":
+		var lastRegexp *regexp.Regexp
+		return func(env *exprEnv) (string, error) {
+			values, err := evalExprArgs(env, []cellExpr{left, right})
+			if err != nil {
+				return "", err
+			}
+
+			// The regular expression is usually the same for each cell, so the last one is kept
+			if lastRegexp == nil || lastRegexp.String() != values[1] {
+				if lastRegexp, err = compileExprRegexp(values[1]); err != nil {
+					return "", err
+				}
+			}
+			return formatExprBool(lastRegexp.MatchString(values[0]) == (op == "=~")), nil
+		}
+	case "+", "-", "*", "/", "%":
+		return numericExpr(func(args []float64) (float64, error) {
+			switch op {
+			case "+":
+				return args[0] + args[1], nil
+			case "-":
+				return args[0] - args[1], nil
+			case "*":
+				return args[0] * args[1], nil
+			}
+
+			if args[1] == 0 {
+				return 0, errors.New("division by zero")
+			} else if op == "%" {
+				return math.Mod(args[0], args[1]), nil
+			}
+			return args[0] / args[1], nil
+		}, left, right)
+	}
+
+	// The comparison operators, which are shared with filter
+	matches := filterOperators[op]
+	return func(env *exprEnv) (string, error) {
+		values, err := evalExprArgs(env, []cellExpr{left, right})
+		if err != nil {
+			return "", err
+		}
+		return formatExprBool(matches(compareValues(values[0], values[1]))), nil
+	}
+}
+
+// Returns an expression which applies fn to the values of the operands as numbers
+func numericExpr(fn func(args []float64) (float64, error), operands ...cellExpr) cellExpr {
+	return func(env *exprEnv) (string, error) {
+		values, err := evalExprArgs(env, operands)
+		if err != nil {
+			return "", err
+		}
+
+		nums, err := parseExprNumbers(values)
+		if err != nil {
+			return "", err
+		}
+
+		result, err := fn(nums)
+		if err != nil {
+			return "", err
+		}
+		return formatExprNumber(result), nil
+	}
+}
+
+func ifExpr(args []cellExpr) (cellExpr, error) {
+	if len(args) < 2 || len(args) > 3 {
+		return nil, errors.New("wrong number of arguments to if")
+	}
+
+	return func(env *exprEnv) (string, error) {
+		cond, err := args[0](env)
+		if err != nil {
+			return "", err
+		}
+
+		if isExprTrue(cond) {
+			return args[1](env)
+		} else if len(args) > 2 {
+			return args[2](env)
+		}
+		return "", nil
+	}, nil
+}
+
+func evalExprArgs(env *exprEnv, args []cellExpr) ([]string, error) {
+	values := make([]string, len(args))
+	for i, arg := range args {
+		var err error
+		if values[i], err = arg(env); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// Parses values as numbers.  Blank values are taken to be 0.
+func parseExprNumbers(values []string) ([]float64, error) {
+	nums := make([]float64, len(values))
+	for i, value := range values {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+
+		n, isNum := parseSortNumber(value)
+		if !isNum {
+			return nil, fmt.Errorf("not a number: '%v'", value)
+		}
+		nums[i] = n
+	}
+	return nums, nil
+}
+
+// Formats a number without an exponent.  The number is rounded to 15 significant digits, so that the
+// errors of floating point arithmetic, such as 0.1 + 0.2 being 0.30000000000000004, are not shown.
+func formatExprNumber(n float64) string {
+	if math.IsInf(n, 0) || math.IsNaN(n) {
+		return strconv.FormatFloat(n, 'g', -1, 64)
+	}
+
+	n, _ = strconv.ParseFloat(strconv.FormatFloat(n, 'g', 15, 64), 64)
+	if n == 0 {
+		n = 0 // Avoid formatting negative zero as "-0"
+	}
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+func formatExprBool(b bool) string {
+	return strconv.FormatBool(b)
+}
+
+// Returns whether a value is true.  Values are false if they are blank, "false" or a number equal to 0.
+func isExprTrue(value string) bool {
+	value = strings.TrimSpace(value)
+	if n, isNum := parseSortNumber(value); isNum {
+		return n != 0
+	}
+	return value != "" && !strings.EqualFold(value, "false")
+}
+
+func compileExprRegexp(expr string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regexp: %v", err)
+	}
+	return re, nil
+}
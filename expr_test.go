@@ -0,0 +1,103 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCellExpr(t *testing.T) {
+	mvc := NewGridViewModel(NewStdModelFromSlice([][]string{
+		{"name", "unit price", "qty", "code"},
+		{"Apple", "1.5", "4", "AB-123"},
+		{" kiwi ", "0.1", "", "x"},
+	}))
+	mvc.SetHeader(1, 0)
+
+	scenarios := []struct {
+		expr     string
+		row      int
+		expected string
+	}{
+		{"42", 1, "42"},
+		{"'a' & \"b\\\"c\"", 1, `ab"c`},
+		{"value", 1, "Apple"},
+		{"row + 1", 2, "3"},
+		{"$2 * ${unit price}", 1, "6"},
+		{"$qty * $1", 2, "0"},
+		{"$1 + 0.2", 2, "0.3"},
+		{"-$2 - 2 * 3", 1, "-10"},
+		{"(1 + 2) * 3 % 5", 1, "4"},
+		{"7 / 2", 1, "3.5"},
+		{"$QTY > 3 && $name =~ '^A'", 1, "true"},
+		{"$qty = '' || 1 / 0", 2, "true"},
+		{"!($code !~ '\\d')", 1, "true"},
+		{"if($qty > 3, 'many', 'few')", 1, "many"},
+		{"if($qty > 5, 1 / 0)", 1, ""},
+		{"upper(trim($name)) & len($name)", 2, "KIWI6"},
+		{"substr($code, 3) & substr($code, 0, 2) & substr($code, 10)", 1, "123AB"},
+		{"replace($code, '-', '')", 1, "AB123"},
+		{"sub($code, '([A-Z]+)-(\\d+)', '$2$1')", 1, "123AB"},
+		{"match($code, '\\d+') & match($code, '([A-Z])([A-Z])', 2) & match($code, 'z(.)')", 1, "123B"},
+		{"round(2.345, 2) & ' ' & round(2.5) & ' ' & floor(-1.5) & ' ' & ceil(1.2) & ' ' & abs(-3)", 1, "2.35 3 -2 2 3"},
+		{"min(3, $qty, 9) + max(1, 2)", 1, "5"},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.expr, func(t *testing.T) {
+			expr, err := parseCellExpr(scenario.expr, mvc.ColumnIndex)
+			assert.NoError(t, err)
+
+			value, err := expr.Eval(mvc.Model(), scenario.row, 0)
+			assert.NoError(t, err)
+			assert.Equal(t, scenario.expected, value)
+		})
+	}
+
+	t.Run("should report errors parsing expressions", func(t *testing.T) {
+		errorScenarios := []struct {
+			expr     string
+			expected string
+		}{
+			{"", "unexpected 'end of expression' at 1"},
+			{"1 +", "unexpected 'end of expression' at 4"},
+			{"(1 + 2", "expected ')' but was 'end of expression' at 7"},
+			{"1 2", "unexpected '2' at 3"},
+			{"'abc", "unterminated string at 1"},
+			{"1 # 2", "unexpected '#' at 3"},
+			{"$price", "no such column: price"},
+			{"$ + 1", "column index or name expected after '$' at 1"},
+			{"nope + 1", "unknown variable 'nope' at 1"},
+			{"nope(1)", "unknown function 'nope' at 1"},
+			{"upper(1, 2)", "wrong number of arguments to upper at 1"},
+			{"if(1)", "wrong number of arguments to if"},
+			{"1.2.3", "invalid number '1.2.3' at 1"},
+		}
+
+		for _, scenario := range errorScenarios {
+			_, err := parseCellExpr(scenario.expr, mvc.ColumnIndex)
+			assert.EqualError(t, err, scenario.expected, scenario.expr)
+		}
+	})
+
+	t.Run("should report errors evaluating expressions", func(t *testing.T) {
+		errorScenarios := []struct {
+			expr     string
+			row      int
+			expected string
+		}{
+			{"$name * 2", 1, "not a number: 'Apple'"},
+			{"1 / $qty", 2, "division by zero"},
+			{"value =~ '('", 2, "invalid regexp: error parsing regexp: missing closing ): `(`"},
+			{"match($code, '(x)', 2)", 2, "match: no group 2"},
+		}
+
+		for _, scenario := range errorScenarios {
+			expr, err := parseCellExpr(scenario.expr, mvc.ColumnIndex)
+			assert.NoError(t, err)
+
+			_, err = expr.Eval(mvc.Model(), scenario.row, 0)
+			assert.EqualError(t, err, scenario.expected, scenario.expr)
+		}
+	})
+}
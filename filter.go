@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// The comparison operators of filter expressions.  Each returns whether the result of comparing the
+// cell value with the operand is a match.
+var filterOperators = map[string]func(cmp int) bool{
+	"=":  func(cmp int) bool { return cmp == 0 },
+	"==": func(cmp int) bool { return cmp == 0 },
+	"!=": func(cmp int) bool { return cmp != 0 },
+	"<":  func(cmp int) bool { return cmp < 0 },
+	"<=": func(cmp int) bool { return cmp <= 0 },
+	">":  func(cmp int) bool { return cmp > 0 },
+	">=": func(cmp int) bool { return cmp >= 0 },
+}
+
+// Parses the arguments of the filter command into a predicate which returns true for rows which
+// match.  The arguments can take one of the following forms:
+//
+//	REGEX             the value of the current column matches the regular expression
+//	COL REGEX         the value of the column matches the regular expression
+//	COL ~ REGEX       as above
+//	COL !~ REGEX      the value of the column does not match the regular expression
+//	COL OP VALUE      the value of the column compares with the value, where OP is one of
+//	                  =, ==, !=, <, <=, > or >=
+//
+// Columns are resolved using columnIndex.  Comparisons use the same ordering as sorting, so numbers
+// and dates are compared by value.
+func parseRowFilter(model Model, args []string, currentCol int, columnIndex func(ref string) (int, error)) (func(row int) bool, error) {
+	col := currentCol
+	if len(args) >= 2 {
+		var err error
+		if col, err = columnIndex(args[0]); err != nil {
+			return nil, err
+		}
+		args = args[1:]
+	}
+
+	switch len(args) {
+	case 1:
+		return regexpRowFilter(model, col, args[0], true)
+	case 2:
+		switch op := args[0]; op {
+		case "~":
+			return regexpRowFilter(model, col, args[1], true)
+		case "!~":
+			return regexpRowFilter(model, col, args[1], false)
+		default:
+			matches, isOperator := filterOperators[op]
+			if !isOperator {
+				return nil, fmt.Errorf("invalid operator: %v", op)
+			}
+
+			operand := args[1]
+			return func(row int) bool {
+				return matches(compareValues(model.CellValue(row, col), operand))
+			}, nil
+		}
+	}
+	return nil, errors.New("Usage: filter [COL] REGEX | filter COL OP VALUE")
+}
+
+func regexpRowFilter(model Model, col int, expr string, wantMatch bool) (func(row int) bool, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regexp: %v", err)
+	}
+
+	return func(row int) bool {
+		return re.MatchString(model.CellValue(row, col)) == wantMatch
+	}, nil
+}
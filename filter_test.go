@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRowFilter(t *testing.T) {
+	model := NewStdModelFromSlice([][]string{
+		{"name", "qty", "date"},
+		{"alice", "9", "2021-03-04"},
+		{"bob", "10", "2020-12-25"},
+		{"carol", "100", "2021-01-01"},
+	})
+	mvc := NewGridViewModel(model)
+
+	scenarios := []struct {
+		args     []string
+		expected []int
+	}{
+		{[]string{"^[ab]"}, []int{1, 2}},
+		{[]string{"1", "0$"}, []int{2, 3}},
+		{[]string{"0", "~", "o"}, []int{2, 3}},
+		{[]string{"0", "!~", "o"}, []int{0, 1}},
+		{[]string{"1", ">", "9"}, []int{0, 2, 3}},
+		{[]string{"1", "<=", "10"}, []int{1, 2}},
+		{[]string{"1", "=", "10.0"}, []int{2}},
+		{[]string{"2", ">=", "2021-01-01"}, []int{0, 1, 3}},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(strings.Join(scenario.args, " "), func(t *testing.T) {
+			pred, err := parseRowFilter(model, scenario.args, 0, mvc.ColumnIndex)
+			assert.NoError(t, err)
+
+			matches := make([]int, 0)
+			for r := 0; r < 4; r++ {
+				if pred(r) {
+					matches = append(matches, r)
+				}
+			}
+			assert.Equal(t, scenario.expected, matches)
+		})
+	}
+
+	t.Run("should return an error for invalid filters", func(t *testing.T) {
+		for _, args := range [][]string{{}, {"("}, {"3", "x"}, {"name", "x"}, {"1", "<>", "2"}, {"1", ">", "2", "3"}} {
+			_, err := parseRowFilter(model, args, 0, mvc.ColumnIndex)
+			assert.Error(t, err, strings.Join(args, " "))
+		}
+	})
+}
+
+func TestModelViewCtrl_FilterRows(t *testing.T) {
+	t.Run("should hide rows and restore them", func(t *testing.T) {
+		mvc := NewGridViewModel(NewStdModelFromSlice([][]string{
+			{"name"}, {"alice"}, {"bob"}, {"carol"},
+		}))
+
+		mvc.FilterRows(1, func(row int) bool { return row != 2 })
+		assert.Equal(t, 3, mvc.VisibleRowCount())
+		assert.Equal(t, 0, mvc.RowAttrs(2).Size)
+
+		mvc.FilterRows(1, func(row int) bool { return row == 2 })
+		assert.Equal(t, 1, mvc.VisibleRowCount())
+		assert.Equal(t, 1, mvc.RowAttrs(0).Size)
+
+		mvc.Unfilter()
+		assert.Equal(t, 4, mvc.VisibleRowCount())
+	})
+
+	t.Run("should not change the model", func(t *testing.T) {
+		rwModel := NewStdModelFromSlice([][]string{{"a"}, {"b"}})
+		mvc := NewGridViewModel(rwModel)
+
+		mvc.FilterRows(0, func(row int) bool { return false })
+
+		assert.False(t, rwModel.IsDirty())
+		assert.Equal(t, ErrNothingToUndo, mvc.Undo())
+	})
+}
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/lmika/ted/ui"
 )
 
@@ -14,19 +17,61 @@ const (
 
 	// EntryMode is when the text entry is selected
 	EntryMode
+
+	// VisualMode is when a range of cells is being selected
+	VisualMode
+)
+
+// The kind of selection made in visual mode
+type VisualKind int
+
+const (
+	// A rectangular range of cells
+	VisualCells VisualKind = iota
+
+	// A range of whole rows
+	VisualRows
+
+	// A range of whole columns
+	VisualCols
 )
 
+var visualKindNames = map[VisualKind]string{
+	VisualCells: "VISUAL",
+	VisualRows:  "VISUAL ROW",
+	VisualCols:  "VISUAL COL",
+}
+
+// CellRange is a range of cells within the model.  The bounds are inclusive.
+type CellRange struct {
+	Row1, Col1 int
+	Row2, Col2 int
+}
+
+// Returns the number of rows and columns within the range
+func (cr CellRange) Dimensions() (int, int) {
+	return cr.Row2 - cr.Row1 + 1, cr.Col2 - cr.Col1 + 1
+}
+
 // A frame is a UI instance.
 type Frame struct {
 	Session *Session
 
 	mode Mode
 
+	// The mode to return to once the prompt is closed
+	promptReturnMode Mode
+
+	visualKind                   VisualKind
+	visualAnchorX, visualAnchorY int
+
 	uiManager       *ui.Ui
 	clientArea      *ui.RelativeLayout
+	overlay         *ui.OverlayLayout
 	grid            *ui.Grid
 	messageView     *ui.TextView
 	textEntry       *ui.TextEntry
+	completionList  *ui.CompletionList
 	statusBar       *ui.StatusBar
 	textEntrySwitch *ui.ProxyLayout
 }
@@ -41,14 +86,17 @@ func NewFrame(uiManager *ui.Ui) *Frame {
 	frame.messageView = &ui.TextView{""}
 	frame.statusBar = &ui.StatusBar{"Test", ""}
 	frame.textEntrySwitch = &ui.ProxyLayout{frame.messageView}
-	frame.textEntry = &ui.TextEntry{}
+	frame.completionList = &ui.CompletionList{}
+	frame.textEntry = &ui.TextEntry{CompletionList: frame.completionList}
 
 	// Build the UI frame
 	statusLayout := &ui.VertLinearLayout{}
+	statusLayout.Append(frame.completionList)
 	statusLayout.Append(frame.statusBar)
 	statusLayout.Append(frame.textEntrySwitch)
 
-	frame.clientArea = &ui.RelativeLayout{Client: frame.grid, South: statusLayout}
+	frame.overlay = &ui.OverlayLayout{Base: frame.grid, MarginX: 2, MarginY: 1}
+	frame.clientArea = &ui.RelativeLayout{Client: frame.overlay, South: statusLayout}
 	return frame
 }
 
@@ -71,12 +119,17 @@ func (frame *Frame) Grid() *ui.Grid {
 func (frame *Frame) enterMode(mode Mode) {
 	switch mode {
 	case GridMode:
-		frame.statusBar.Left = frame.Session.Source.String()
+		frame.updateStatusBar()
 
 		frame.uiManager.SetFocusedComponent(frame)
 	case EntryMode:
 		frame.textEntrySwitch.Component = frame.textEntry
 		frame.uiManager.SetFocusedComponent(frame.textEntry)
+	case VisualMode:
+		frame.updateStatusBar()
+		frame.updateSelection()
+
+		frame.uiManager.SetFocusedComponent(frame)
 	}
 }
 
@@ -85,6 +138,8 @@ func (frame *Frame) exitMode(mode Mode) {
 	switch mode {
 	case EntryMode:
 		frame.textEntrySwitch.Component = frame.messageView
+	case VisualMode:
+		frame.grid.ClearSelection()
 	}
 }
 
@@ -94,6 +149,83 @@ func (frame *Frame) setMode(mode Mode) {
 	frame.enterMode(frame.mode)
 }
 
+// EnterVisualMode starts selecting a range of cells, anchored at the current cell.
+func (frame *Frame) EnterVisualMode(kind VisualKind) {
+	if frame.mode == VisualMode && frame.visualKind == kind {
+		frame.ExitVisualMode()
+		return
+	}
+
+	frame.visualKind = kind
+	frame.visualAnchorX, frame.visualAnchorY = frame.grid.CellPosition()
+	frame.setMode(VisualMode)
+}
+
+// ExitVisualMode clears the selection and returns to grid mode.
+func (frame *Frame) ExitVisualMode() {
+	if frame.mode == VisualMode {
+		frame.setMode(GridMode)
+	}
+}
+
+// SelectionKind returns the kind of selection being made.  The second value will be false if not
+// in visual mode.
+func (frame *Frame) SelectionKind() (VisualKind, bool) {
+	return frame.visualKind, frame.mode == VisualMode
+}
+
+// SelectedRange returns the range of cells commands should operate on.  In visual mode this
+// is the selection, otherwise it is the currently selected cell.
+func (frame *Frame) SelectedRange() CellRange {
+	cellX, cellY := frame.grid.CellPosition()
+	if frame.mode != VisualMode {
+		return CellRange{Row1: cellY, Col1: cellX, Row2: cellY, Col2: cellX}
+	}
+
+	x1, y1, x2, y2, _ := frame.grid.Selection()
+	return CellRange{Row1: y1, Col1: x1, Row2: y2, Col2: x2}
+}
+
+// Updates the grid selection from the visual anchor and the current cell
+func (frame *Frame) updateSelection() {
+	cellX, cellY := frame.grid.CellPosition()
+	maxX, maxY := frame.grid.Model().Dimensions()
+
+	switch frame.visualKind {
+	case VisualRows:
+		frame.grid.SetSelection(0, frame.visualAnchorY, maxX-1, cellY)
+	case VisualCols:
+		frame.grid.SetSelection(frame.visualAnchorX, 0, cellX, maxY-1)
+	default:
+		frame.grid.SetSelection(frame.visualAnchorX, frame.visualAnchorY, cellX, cellY)
+	}
+}
+
+// Updates the status bar with the current source, its format and the current mode
+func (frame *Frame) updateStatusBar() {
+	if frame.Session == nil {
+		return
+	}
+
+	frame.statusBar.Left = frame.Session.Source.String()
+	if rwModel, isRwModel := frame.Session.modelController.Model().(RWModel); isRwModel && rwModel.IsDirty() {
+		frame.statusBar.Left += " [+]"
+	}
+
+	indicators := make([]string, 0)
+	rows, _ := frame.Session.modelController.Model().Dimensions()
+	if visibleRows := frame.Session.modelController.VisibleRowCount(); visibleRows < rows {
+		indicators = append(indicators, fmt.Sprintf("%d of %d rows", visibleRows, rows))
+	}
+	if frame.mode == VisualMode {
+		indicators = append(indicators, visualKindNames[frame.visualKind])
+	}
+	if describer, isDescriber := frame.Session.Source.(DescribableModelSource); isDescriber {
+		indicators = append(indicators, describer.Format())
+	}
+	frame.statusBar.Right = strings.Join(indicators, "  ")
+}
+
 // Message sets the message view's message
 func (frame *Frame) Message(s string) {
 	frame.messageView.Text = s
@@ -109,10 +241,21 @@ type PromptOptions struct {
 	Prompt                 string
 	InitialValue           string
 	CancelOnEmptyBackspace bool
+
+	// The history of entries, which can be recalled and which entries are added to
+	History *History
+
+	// Completes the entry when Tab is pressed
+	Completer ui.Completer
 }
 
 // Prompt the user for input.  This switches the mode to entry mode.
 func (frame *Frame) Prompt(options PromptOptions, callback func(res string) error) {
+	frame.textEntry.History = nil
+	if options.History != nil {
+		frame.textEntry.History = options.History.Entries
+	}
+	frame.textEntry.Completer = options.Completer
 	frame.textEntry.Reset()
 	frame.textEntry.Prompt = options.Prompt
 	frame.textEntry.CancelOnEmptyBackspace = options.CancelOnEmptyBackspace
@@ -120,18 +263,26 @@ func (frame *Frame) Prompt(options PromptOptions, callback func(res string) erro
 
 	frame.textEntry.OnCancel = frame.exitEntryMode
 	frame.textEntry.OnEntry = func(res string) {
+		if options.History != nil {
+			options.History.Add(res)
+		}
 		frame.exitEntryMode()
 		if err := callback(res); err != nil {
 			frame.Error(err)
 		}
+		frame.updateStatusBar()
 	}
 
+	frame.promptReturnMode = GridMode
+	if frame.mode == VisualMode {
+		frame.promptReturnMode = VisualMode
+	}
 	frame.setMode(EntryMode)
 }
 
 func (frame *Frame) exitEntryMode() {
 	frame.textEntry.OnEntry = nil
-	frame.setMode(GridMode)
+	frame.setMode(frame.promptReturnMode)
 }
 
 // Show a message.  This will switch the bottom to the messageView and select the frame
@@ -141,6 +292,21 @@ func (frame *Frame) ShowMessage(msg string) {
 	//frame.EnterMode(GridMode)
 }
 
+// ShowPanel shows lines of text in a panel over the grid.  The panel has the focus until it is closed.
+// The panel is returned so that the lines can be changed while it is shown.
+func (frame *Frame) ShowPanel(title string, lines []string) *ui.TextPanel {
+	panel := &ui.TextPanel{Title: title, Lines: lines, OnClose: frame.ClosePanel}
+	frame.overlay.Overlay = panel
+	frame.uiManager.SetFocusedComponent(panel)
+	return panel
+}
+
+// ClosePanel closes the panel shown over the grid, and returns the focus to the current mode.
+func (frame *Frame) ClosePanel() {
+	frame.overlay.Overlay = nil
+	frame.enterMode(frame.mode)
+}
+
 // Shows the value of the currently select grid cell
 func (frame *Frame) ShowCellValue() {
 	displayValue := frame.grid.CurrentCellDisplayValue()
@@ -152,4 +318,13 @@ func (frame *Frame) KeyPressed(key rune, mod int) {
 	if frame.Session != nil {
 		frame.Session.KeyPressed(key, mod)
 	}
+	frame.keysHandled()
+}
+
+// Updates the selection and status bar once keys have been handled by the session
+func (frame *Frame) keysHandled() {
+	if frame.mode == VisualMode {
+		frame.updateSelection()
+	}
+	frame.updateStatusBar()
 }
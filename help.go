@@ -0,0 +1,148 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// The help of a command
+type commandHelp struct {
+	Name    string
+	Aliases []string
+	Keys    []string
+	Doc     string
+
+	// The expression of an alias which is not for a single command
+	Expr string
+}
+
+// Returns the help of all commands in order of name.  Aliases of a command are listed with the command,
+// while aliases of other expressions are listed separately.  Key mappings of expressions which are not
+// commands or aliases are returned as the second value, formatted as lines.
+func (cm *CommandMapping) commandHelp() ([]*commandHelp, []string) {
+	keysOfCommands := make(map[*Command][]string)
+	cm.KeyMappings.walk(nil, func(keys []rune, cmd *Command) {
+		keysOfCommands[cmd] = append(keysOfCommands[cmd], formatKeys(keys))
+	})
+
+	names := commandNames(cm)
+	helpOfCommands := make(map[*Command]*commandHelp)
+	helps := make([]*commandHelp, 0, len(names))
+	for _, name := range names {
+		if cmd := cm.Commands[name]; cmd.Name == name {
+			help := &commandHelp{Name: name, Keys: keysOfCommands[cmd], Doc: cmd.Doc}
+			helpOfCommands[cmd] = help
+			helps = append(helps, help)
+		}
+	}
+
+	for _, name := range names {
+		cmd := cm.Commands[name]
+		if cmd.Name == name {
+			continue
+		} else if help, isCommand := helpOfCommands[cmd]; isCommand {
+			help.Aliases = append(help.Aliases, name)
+		} else {
+			help := &commandHelp{Name: name, Keys: keysOfCommands[cmd], Doc: cmd.Doc, Expr: cmd.Name}
+			helpOfCommands[cmd] = help
+			helps = append(helps, help)
+		}
+	}
+	sort.Slice(helps, func(i, j int) bool { return helps[i].Name < helps[j].Name })
+
+	otherMappings := make([]string, 0)
+	cm.KeyMappings.walk(nil, func(keys []rune, cmd *Command) {
+		if _, hasHelp := helpOfCommands[cmd]; !hasHelp {
+			otherMappings = append(otherMappings, formatKeys(keys)+"  "+cmd.Name)
+		}
+	})
+	return helps, otherMappings
+}
+
+// Returns the help of the command with the name or alias, or nil if there is no such command
+func (cm *CommandMapping) commandHelpOf(name string) *commandHelp {
+	cmd := cm.Commands[name]
+	if cmd == nil {
+		return nil
+	}
+
+	helps, _ := cm.commandHelp()
+	for _, help := range helps {
+		if cm.Commands[help.Name] == cmd {
+			return help
+		}
+	}
+	return nil
+}
+
+// Returns the lines of help of the commands.  The first line of each command has the name, aliases and
+// keys of the command, with the keys aligned to the given column.  This is followed by an indented line
+// with the doc.
+func helpLines(helps []*commandHelp, keysCol int) []string {
+	lines := make([]string, 0, len(helps)*2)
+	for _, help := range helps {
+		heading := help.heading()
+		if len(help.Keys) > 0 {
+			heading += strings.Repeat(" ", maxInt(keysCol-len(heading), 2)) + strings.Join(help.Keys, ", ")
+		}
+		lines = append(lines, heading)
+
+		if help.Doc != "" {
+			lines = append(lines, "    "+help.Doc)
+		}
+	}
+	return lines
+}
+
+// Returns the name of the command with the aliases or expression
+func (help *commandHelp) heading() string {
+	heading := help.Name
+	if len(help.Aliases) > 0 {
+		heading += " (" + strings.Join(help.Aliases, ", ") + ")"
+	}
+	if help.Expr != "" {
+		heading += " = " + help.Expr
+	}
+	return heading
+}
+
+// Returns the column to align the keys of the help to
+func helpKeysCol(helps []*commandHelp) int {
+	col := 0
+	for _, help := range helps {
+		col = maxInt(col, len(help.heading())+2)
+	}
+	return minInt(col, 32)
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Walks the key mappings in order of their keys, calling fn with the keys and the command of each
+// mapping.  The keys slice is only valid during the call.
+func (km *KeyMapping) walk(prefix []rune, fn func(keys []rune, cmd *Command)) {
+	if km.Command != nil {
+		fn(prefix, km.Command)
+	}
+
+	keys := make([]rune, 0, len(km.Children))
+	for key := range km.Children {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	for _, key := range keys {
+		km.Children[key].walk(append(prefix, key), fn)
+	}
+}
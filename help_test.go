@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandMapping_CommandHelp(t *testing.T) {
+	cm := NewCommandMapping()
+	cm.Define("save", "Save current file", "", func(ctx *CommandContext) error { return nil })
+	cm.Define("clear", "Clear cells", "", func(ctx *CommandContext) error { return nil })
+	cm.Commands["w"] = cm.Command("save")
+	cm.MapKeys([]rune("zs"), cm.Command("save"))
+	cm.MapKey('x', cm.Command("clear"))
+
+	fill, _ := cm.ExprCommand("clear; save")
+	cm.Commands["cs"] = fill
+	cm.MapKey('c', fill)
+
+	other, _ := cm.ExprCommand("save; clear")
+	cm.MapKey('S', other)
+
+	t.Run("should list commands with their aliases and keys", func(t *testing.T) {
+		helps, otherMappings := cm.commandHelp()
+
+		assert.Equal(t, []*commandHelp{
+			{Name: "clear", Keys: []string{"x"}, Doc: "Clear cells"},
+			{Name: "cs", Keys: []string{"c"}, Doc: "clear; save", Expr: "clear; save"},
+			{Name: "save", Aliases: []string{"w"}, Keys: []string{"zs"}, Doc: "Save current file"},
+		}, helps)
+		assert.Equal(t, []string{"S  save; clear"}, otherMappings)
+	})
+
+	t.Run("should return the help of a command by alias", func(t *testing.T) {
+		help := cm.commandHelpOf("w")
+		assert.Equal(t, "save", help.Name)
+		assert.Nil(t, cm.commandHelpOf("nope"))
+	})
+
+	t.Run("should format help lines with the keys aligned", func(t *testing.T) {
+		helps, _ := cm.commandHelp()
+
+		assert.Equal(t, []string{
+			"clear             x",
+			"    Clear cells",
+			"cs = clear; save  c",
+			"    clear; save",
+			"save (w)          zs",
+			"    Save current file",
+		}, helpLines(helps, helpKeysCol(helps)))
+	})
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The maximum number of entries kept in a history
+const maxHistoryEntries = 200
+
+// A history of the entries made at a prompt, oldest first
+type History struct {
+	Entries []string
+}
+
+// Add adds an entry to the end of the history.  An earlier entry with the same value is removed, and
+// blank entries are ignored.
+func (h *History) Add(entry string) {
+	if strings.TrimSpace(entry) == "" {
+		return
+	}
+
+	entries := make([]string, 0, len(h.Entries)+1)
+	for _, e := range h.Entries {
+		if e != entry {
+			entries = append(entries, e)
+		}
+	}
+	entries = append(entries, entry)
+
+	if len(entries) > maxHistoryEntries {
+		entries = entries[len(entries)-maxHistoryEntries:]
+	}
+	h.Entries = entries
+}
+
+// Returns the path of the file holding the command history, which is in the per-user state directory
+func historyPath() (string, error) {
+	stateDir, err := userStateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(stateDir, "ted", "history"), nil
+}
+
+// Load reads the history from a file, with one entry per line.  A missing file is not an error.
+func (h *History) Load(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h.Entries = nil
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		h.Add(scanner.Text())
+	}
+	return scanner.Err()
+}
+
+// Save writes the history to a file, creating the directory if necessary.
+func (h *History) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	return atomicWriteFile(path, func(w io.Writer) error {
+		for _, entry := range h.Entries {
+			if _, err := io.WriteString(w, entry+"\n"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistory_Add(t *testing.T) {
+	t.Run("should move repeated entries to the end", func(t *testing.T) {
+		h := &History{}
+
+		h.Add("save")
+		h.Add("sort 1")
+		h.Add(" ")
+		h.Add("save")
+
+		assert.Equal(t, []string{"sort 1", "save"}, h.Entries)
+	})
+
+	t.Run("should drop the oldest entries", func(t *testing.T) {
+		h := &History{}
+		for i := 0; i < maxHistoryEntries+5; i++ {
+			h.Add(fmt.Sprint(i))
+		}
+
+		assert.Len(t, h.Entries, maxHistoryEntries)
+		assert.Equal(t, "5", h.Entries[0])
+	})
+}
+
+func TestHistory_LoadAndSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ted", "history")
+	h := &History{}
+
+	assert.NoError(t, h.Load(path))
+	assert.Empty(t, h.Entries)
+
+	h.Add("set header 1")
+	h.Add(`fill "a b"; to-upper`)
+	assert.NoError(t, h.Save(path))
+
+	loaded := &History{}
+	assert.NoError(t, loaded.Load(path))
+	assert.Equal(t, h.Entries, loaded.Entries)
+}
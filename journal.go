@@ -0,0 +1,177 @@
+package main
+
+// The maximum number of undo steps kept by the journal
+const maxJournalSteps = 500
+
+// A change made to the model which can be reverted and reapplied.
+type modelChange interface {
+	// Reverts the change
+	undo(gvm *ModelViewCtrl)
+
+	// Reapplies the change
+	redo(gvm *ModelViewCtrl)
+}
+
+// A group of changes which are undone and redone as a single step.
+type changeGroup []modelChange
+
+// The change journal keeps track of changes made to the model for undo and redo.
+type changeJournal struct {
+	undoStack []changeGroup
+	redoStack []changeGroup
+
+	pending    changeGroup
+	groupDepth int
+}
+
+// Clears the journal
+func (j *changeJournal) reset() {
+	j.undoStack = nil
+	j.redoStack = nil
+	j.pending = nil
+	j.groupDepth = 0
+}
+
+// Records a change.  If a group is open, the change is added to the group, otherwise
+// it becomes a step by itself.
+func (j *changeJournal) record(change modelChange) {
+	if j.groupDepth > 0 {
+		j.pending = append(j.pending, change)
+		return
+	}
+	j.pushStep(changeGroup{change})
+}
+
+func (j *changeJournal) beginGroup() {
+	j.groupDepth++
+}
+
+func (j *changeJournal) endGroup() {
+	if j.groupDepth == 0 {
+		return
+	}
+
+	j.groupDepth--
+	if j.groupDepth == 0 && len(j.pending) > 0 {
+		j.pushStep(j.pending)
+		j.pending = nil
+	}
+}
+
+func (j *changeJournal) pushStep(group changeGroup) {
+	j.undoStack = append(j.undoStack, group)
+	if len(j.undoStack) > maxJournalSteps {
+		j.undoStack = j.undoStack[len(j.undoStack)-maxJournalSteps:]
+	}
+	j.redoStack = nil
+}
+
+// A change to the value of a single cell
+type cellValueChange struct {
+	row, col           int
+	oldValue, newValue string
+}
+
+func (c cellValueChange) undo(gvm *ModelViewCtrl) {
+	gvm.rwModel().SetCellValue(c.row, c.col, c.oldValue)
+}
+
+func (c cellValueChange) redo(gvm *ModelViewCtrl) {
+	gvm.rwModel().SetCellValue(c.row, c.col, c.newValue)
+}
+
+// A change to the dimensions of the model.  Cells and attributes which were dropped
+// when the model shrunk are retained so that they can be restored.
+type resizeChange struct {
+	oldRows, oldCols int
+	newRows, newCols int
+
+	droppedCells    []cellValueChange
+	droppedRowAttrs []SliceAttr
+	droppedColAttrs []SliceAttr
+}
+
+func (c resizeChange) undo(gvm *ModelViewCtrl) {
+	gvm.resizeModel(c.oldRows, c.oldCols)
+
+	rw := gvm.rwModel()
+	for _, cell := range c.droppedCells {
+		rw.SetCellValue(cell.row, cell.col, cell.oldValue)
+	}
+	if c.newRows < len(gvm.rowAttrs) {
+		copy(gvm.rowAttrs[c.newRows:], c.droppedRowAttrs)
+	}
+	if c.newCols < len(gvm.colAttrs) {
+		copy(gvm.colAttrs[c.newCols:], c.droppedColAttrs)
+	}
+}
+
+func (c resizeChange) redo(gvm *ModelViewCtrl) {
+	gvm.resizeModel(c.newRows, c.newCols)
+}
+
+// The axis of a structural change
+type sliceAxis int
+
+const (
+	rowAxis sliceAxis = iota
+	colAxis
+)
+
+// The contents of a single row or column, along with its attributes
+type sliceSnapshot struct {
+	values []string
+	attrs  SliceAttr
+}
+
+// The insertion or removal of a row or column
+type sliceChange struct {
+	axis     sliceAxis
+	index    int
+	inserted bool
+	snapshot sliceSnapshot
+}
+
+func (c sliceChange) undo(gvm *ModelViewCtrl) {
+	if c.inserted {
+		gvm.removeSlice(c.axis, c.index)
+	} else {
+		gvm.insertSlice(c.axis, c.index, c.snapshot)
+	}
+}
+
+func (c sliceChange) redo(gvm *ModelViewCtrl) {
+	if c.inserted {
+		gvm.insertSlice(c.axis, c.index, c.snapshot)
+	} else {
+		gvm.removeSlice(c.axis, c.index)
+	}
+}
+
+// A change to the order of the rows, such as a sort.  Row fromRow+i was moved from the row at order[i].
+type rowOrderChange struct {
+	fromRow int
+	order   []int
+}
+
+func (c rowOrderChange) undo(gvm *ModelViewCtrl) {
+	inverse := make([]int, len(c.order))
+	for i, r := range c.order {
+		inverse[r-c.fromRow] = c.fromRow + i
+	}
+	gvm.reorderRows(c.fromRow, inverse)
+}
+
+func (c rowOrderChange) redo(gvm *ModelViewCtrl) {
+	gvm.reorderRows(c.fromRow, c.order)
+}
+
+// Returns true if the change leaves all the rows in place
+func (c rowOrderChange) isIdentity() bool {
+	for i, r := range c.order {
+		if r != c.fromRow+i {
+			return false
+		}
+	}
+	return true
+}
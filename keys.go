@@ -0,0 +1,177 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lmika/ted/ui"
+)
+
+// The names of special keys, as used between angle brackets, such as <Esc>.  Names are matched
+// ignoring case.
+var keyNames = map[string]rune{
+	"space":     ' ',
+	"lt":        '<',
+	"tab":       ui.KeyCtrlI,
+	"cr":        ui.KeyEnter,
+	"enter":     ui.KeyEnter,
+	"return":    ui.KeyEnter,
+	"esc":       ui.KeyEsc,
+	"bs":        ui.KeyBackspace2,
+	"backspace": ui.KeyBackspace2,
+	"del":       ui.KeyDelete,
+	"delete":    ui.KeyDelete,
+	"insert":    ui.KeyInsert,
+	"home":      ui.KeyHome,
+	"end":       ui.KeyEnd,
+	"pageup":    ui.KeyPgup,
+	"pagedown":  ui.KeyPgdn,
+	"up":        ui.KeyArrowUp,
+	"down":      ui.KeyArrowDown,
+	"left":      ui.KeyArrowLeft,
+	"right":     ui.KeyArrowRight,
+	"f1":        ui.KeyF1,
+	"f2":        ui.KeyF2,
+	"f3":        ui.KeyF3,
+	"f4":        ui.KeyF4,
+	"f5":        ui.KeyF5,
+	"f6":        ui.KeyF6,
+	"f7":        ui.KeyF7,
+	"f8":        ui.KeyF8,
+	"f9":        ui.KeyF9,
+	"f10":       ui.KeyF10,
+	"f11":       ui.KeyF11,
+	"f12":       ui.KeyF12,
+}
+
+// The names of keys when they are shown
+var keyDisplayNames = map[rune]string{
+	' ':              "<Space>",
+	'<':              "<lt>",
+	ui.KeyCtrlSpace:  "<C-Space>",
+	ui.KeyCtrlI:      "<Tab>",
+	ui.KeyEnter:      "<Enter>",
+	ui.KeyEsc:        "<Esc>",
+	ui.KeyBackspace2: "<BS>",
+	ui.KeyDelete:     "<Del>",
+	ui.KeyInsert:     "<Insert>",
+	ui.KeyHome:       "<Home>",
+	ui.KeyEnd:        "<End>",
+	ui.KeyPgup:       "<PageUp>",
+	ui.KeyPgdn:       "<PageDown>",
+	ui.KeyArrowUp:    "<Up>",
+	ui.KeyArrowDown:  "<Down>",
+	ui.KeyArrowLeft:  "<Left>",
+	ui.KeyArrowRight: "<Right>",
+	ui.KeyF1:         "<F1>",
+	ui.KeyF2:         "<F2>",
+	ui.KeyF3:         "<F3>",
+	ui.KeyF4:         "<F4>",
+	ui.KeyF5:         "<F5>",
+	ui.KeyF6:         "<F6>",
+	ui.KeyF7:         "<F7>",
+	ui.KeyF8:         "<F8>",
+	ui.KeyF9:         "<F9>",
+	ui.KeyF10:        "<F10>",
+	ui.KeyF11:        "<F11>",
+	ui.KeyF12:        "<F12>",
+}
+
+// Formats a sequence of keys in the form read by parseKeys
+func formatKeys(keys []rune) string {
+	var sb strings.Builder
+	for _, key := range keys {
+		sb.WriteString(formatKey(key))
+	}
+	return sb.String()
+}
+
+// Formats a single key in the form read by parseKeys
+func formatKey(key rune) string {
+	if key&ModAlt != 0 {
+		name := formatKey(key &^ ModAlt)
+		if strings.HasPrefix(name, "<") && len(name) > 1 {
+			return "<M-" + name[1:]
+		}
+		return "<M-" + name + ">"
+	}
+
+	if name, hasName := keyDisplayNames[key]; hasName {
+		return name
+	} else if key >= ui.KeyCtrlA && key <= ui.KeyCtrlZ {
+		return "<C-" + string('a'+key-ui.KeyCtrlA) + ">"
+	}
+	return string(key)
+}
+
+// Parses a sequence of keys.  Most characters stand for themselves, while special keys are named
+// between angle brackets, such as <Esc> or <PageDown>.  Within the brackets, the prefix "C-" stands for
+// the control key, and "M-" for the alt key, as in <C-r> or <M-x>.
+func parseKeys(s string) ([]rune, error) {
+	keys := make([]rune, 0)
+	for s != "" {
+		if s[0] == '<' {
+			if end := strings.IndexByte(s, '>'); end > 1 {
+				key, err := parseKeyName(s[1:end])
+				if err != nil {
+					return nil, err
+				}
+				keys = append(keys, key)
+				s = s[end+1:]
+				continue
+			}
+		}
+
+		r, size := utf8.DecodeRuneInString(s)
+		keys = append(keys, r)
+		s = s[size:]
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no keys")
+	}
+	return keys, nil
+}
+
+// Parses the name of a key found between angle brackets
+func parseKeyName(name string) (rune, error) {
+	var ctrl, alt bool
+	rest := name
+	for len(rest) > 2 && rest[1] == '-' {
+		switch rest[0] {
+		case 'C', 'c':
+			ctrl = true
+		case 'M', 'm', 'A', 'a':
+			alt = true
+		default:
+			return 0, fmt.Errorf("invalid key: <%v>", name)
+		}
+		rest = rest[2:]
+	}
+
+	key, isNamed := keyNames[strings.ToLower(rest)]
+	if !isNamed {
+		r, size := utf8.DecodeRuneInString(rest)
+		if size != len(rest) {
+			return 0, fmt.Errorf("invalid key: <%v>", name)
+		}
+		key = r
+	}
+
+	if ctrl {
+		switch lower := key | 0x20; {
+		case key == ' ':
+			key = ui.KeyCtrlSpace
+		case lower >= 'a' && lower <= 'z':
+			key = ui.KeyCtrlA + (lower - 'a')
+		default:
+			return 0, fmt.Errorf("invalid key: <%v>", name)
+		}
+	}
+	if alt {
+		key |= ModAlt
+	}
+	return key, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/lmika/ted/ui"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseKeys(t *testing.T) {
+	scenarios := []struct {
+		keys     string
+		expected []rune
+	}{
+		{"h", []rune{'h'}},
+		{"gg", []rune{'g', 'g'}},
+		{"é", []rune{'é'}},
+		{"<", []rune{'<'}},
+		{"<lt>", []rune{'<'}},
+		{"<Esc>", []rune{ui.KeyEsc}},
+		{"<pageDOWN>", []rune{ui.KeyPgdn}},
+		{"<C-r>", []rune{ui.KeyCtrlR}},
+		{"<C-R>", []rune{ui.KeyCtrlR}},
+		{"<C-Space>", []rune{ui.KeyCtrlSpace}},
+		{"<M-x>", []rune{'x' | ModAlt}},
+		{"<M-C-a>", []rune{ui.KeyCtrlA | ModAlt}},
+		{"<Up>k", []rune{ui.KeyArrowUp, 'k'}},
+		{"<>", []rune{'<', '>'}},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.keys, func(t *testing.T) {
+			keys, err := parseKeys(scenario.keys)
+
+			assert.NoError(t, err)
+			assert.Equal(t, scenario.expected, keys)
+		})
+	}
+
+	t.Run("should return an error for invalid keys", func(t *testing.T) {
+		for _, keys := range []string{"", "<nope>", "<C-1>", "<X-a>"} {
+			_, err := parseKeys(keys)
+			assert.Error(t, err, keys)
+		}
+	})
+}
+
+func TestFormatKeys(t *testing.T) {
+	scenarios := []struct {
+		keys     []rune
+		expected string
+	}{
+		{[]rune{'h'}, "h"},
+		{[]rune{'g', 'g'}, "gg"},
+		{[]rune{'<'}, "<lt>"},
+		{[]rune{ui.KeyEsc}, "<Esc>"},
+		{[]rune{ui.KeyCtrlR}, "<C-r>"},
+		{[]rune{'x' | ModAlt}, "<M-x>"},
+		{[]rune{ui.KeyArrowUp | ModAlt}, "<M-Up>"},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.expected, func(t *testing.T) {
+			formatted := formatKeys(scenario.keys)
+			assert.Equal(t, scenario.expected, formatted)
+
+			parsed, err := parseKeys(formatted)
+			assert.NoError(t, err)
+			assert.Equal(t, scenario.keys, parsed)
+		})
+	}
+}
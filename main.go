@@ -8,33 +8,87 @@ import (
 )
 
 func main() {
-	var flagCodec = flag.String("c", "csv", "file codec to use")
+	var flagCodec = flag.String("c", "", "file codec to use (default is detected from the file)")
+
+	// Flags which have the same names as the settings they change
+	flag.Bool("backup", false, "keep a backup of the previous version of the file when saving")
+	flag.String("delimiter", "", "field delimiter of CSV files")
+	flag.String("comment", "", "ignore lines of CSV files starting with this character")
+	flag.Bool("lazy-quotes", false, "allow quotes within unquoted fields of CSV files")
+	flag.Bool("trim-space", false, "ignore leading white space of fields in CSV files")
+	flag.Bool("crlf", false, "write CSV files with CRLF line endings")
+	flag.String("quote", "minimal", "when to quote fields of CSV files: 'minimal' or 'all'")
+	flag.Bool("md-pad", false, "pad the cells of markdown tables so that the columns line up")
+	flag.String("header", "0", "number of header rows, and optionally columns, to keep on screen: ROWS[,COLS]")
+	flag.Bool("fit-on-load", false, "fit the widths of all columns to their values")
+	flag.Int("min-col-width", defaultMinColWidth, "minimum width of columns fitted to their values")
+	flag.Int("max-col-width", defaultMaxColWidth, "maximum width of columns fitted to their values")
+	flag.String("view-state", ViewStateNone, "where to keep the column widths, row markers and cursor position of files: 'none', 'sidecar' or 'user'")
 	flag.Parse()
 	if flag.NArg() == 0 {
 		fmt.Fprintln(os.Stderr, "usage: ted FILENAME")
 		os.Exit(1)
 	}
 
+	var source ModelSource
+	if *flagCodec != "" {
+		codecBuilder, hasCodec := codecModelSourceBuilders[*flagCodec]
+		if !hasCodec {
+			fmt.Fprintf(os.Stderr, "unrecognised codec: %v\n", *flagCodec)
+			os.Exit(1)
+		}
+		source = codecBuilder(flag.Arg(0))
+	} else {
+		source = detectModelSource(flag.Arg(0))
+	}
+
 	uiManager, err := ui.NewUI()
 	if err != nil {
 		panic(err)
 	}
 	defer uiManager.Close()
 
-	codecBuilder, hasCodec := codecModelSourceBuilders[*flagCodec]
-	if !hasCodec {
-		fmt.Fprintf(os.Stderr, "unrecognised codec: %v", *flagCodec)
+	frame := NewFrame(uiManager)
+	session := NewSession(uiManager, frame, source)
+	if path, err := configPath(); err == nil {
+		session.Frame.Error(session.LoadConfig(path))
+	}
+	historyFile, historyErr := historyPath()
+	if historyErr == nil {
+		session.Frame.Error(session.CommandHistory.Load(historyFile))
+	}
+	if err := session.LoadFromSourceWithSettings(settingFlags(session)); err != nil {
+		uiManager.Close()
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 
-	frame := NewFrame(uiManager)
-	session := NewSession(uiManager, frame, codecBuilder(flag.Arg(0)))
-	session.LoadFromSource()
-
 	uiManager.SetRootComponent(frame.RootComponent())
-	frame.enterMode(GridMode)
+	frame.setMode(GridMode)
 
 	uiManager.Loop()
+	uiManager.Close()
+
+	if historyErr == nil {
+		if err := session.CommandHistory.Save(historyFile); err != nil {
+			fmt.Fprintf(os.Stderr, "cannot save command history: %v\n", err)
+		}
+	}
+	if err := session.SaveViewStateOnQuit(); err != nil {
+		fmt.Fprintf(os.Stderr, "cannot save view state: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// Returns the values of the flags set on the command line which change settings
+func settingFlags(session *Session) map[string]string {
+	settings := make(map[string]string)
+	flag.Visit(func(f *flag.Flag) {
+		if session.Settings.Setting(f.Name) != nil {
+			settings[f.Name] = f.Value.String()
+		}
+	})
+	return settings
 }
 
 type codecModelSourceBuilder func(filename string) ModelSource
@@ -47,6 +101,9 @@ var codecModelSourceBuilders = map[string]codecModelSourceBuilder{
 		return NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: '\t'})
 	},
 	"jira": func(filename string) ModelSource {
-		return JiraTableModelSource{Filename: filename, Header: true}
+		return &JiraTableModelSource{Filename: filename, Header: true}
+	},
+	"markdown": func(filename string) ModelSource {
+		return &MarkdownTableModelSource{Filename: filename}
 	},
 }
@@ -25,6 +25,9 @@ type RWModel interface {
 
 	// Returns true if the model has been modified in some way
 	IsDirty() bool
+
+	// Marks the model as unmodified, such as when it has been saved
+	ClearDirty()
 }
 
 
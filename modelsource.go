@@ -1,13 +1,19 @@
 package main
 
 import (
+	"bufio"
 	"encoding/csv"
-	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
 )
 
 // ModelSource is a source of models.  At a minimum, it must be able to read models.
@@ -27,117 +33,481 @@ type WritableModelSource interface {
 	Write(m Model) error
 }
 
+// A model source backed by a file
+type FileModelSource interface {
+	ModelSource
+
+	// Path returns the path of the file
+	Path() string
+}
+
+// A model source which can describe the format of the data it reads and writes, such as
+// the codec and delimiter.
+type DescribableModelSource interface {
+	ModelSource
+
+	// Format describes the format of the source
+	Format() string
+}
+
 // A model source backed by a CSV file
 type CsvFileModelSource struct {
 	filename string
 	options  CsvFileModelSourceOptions
+
+	// The formatting of the file when it was last read
+	format *csvFileFormat
 }
 
 type CsvFileModelSourceOptions struct {
+	// The field delimiter
 	Comma rune
+
+	// Lines starting with this character are ignored.  Set to 0 to disable comments.
+	Comment rune
+
+	// Allow quotes to appear within unquoted fields, and unescaped quotes within quoted fields
+	LazyQuotes bool
+
+	// Ignore leading white space in fields
+	TrimLeadingSpace bool
+
+	// Write lines ending with CRLF instead of LF
+	UseCRLF bool
+
+	// Quote every field when writing, instead of only those that need it
+	QuoteAll bool
 }
 
-func NewCsvFileModelSource(filename string, options CsvFileModelSourceOptions) CsvFileModelSource {
-	return CsvFileModelSource{
+func NewCsvFileModelSource(filename string, options CsvFileModelSourceOptions) *CsvFileModelSource {
+	return &CsvFileModelSource{
 		filename: filename,
 		options:  options,
 	}
 }
 
 // Describes the source
-func (s CsvFileModelSource) String() string {
+func (s *CsvFileModelSource) String() string {
 	return filepath.Base(s.filename)
 }
 
-// Read the model from the given source
-func (s CsvFileModelSource) Read() (Model, error) {
+func (s *CsvFileModelSource) Path() string {
+	return s.filename
+}
+
+// Returns the options of the source
+func (s *CsvFileModelSource) Options() CsvFileModelSourceOptions {
+	return s.options
+}
+
+// Changes the options of the source.  These will be used the next time the source is read or written.
+func (s *CsvFileModelSource) SetOptions(options CsvFileModelSourceOptions) {
+	s.options = options
+}
+
+// Describes the codec and delimiter of the source
+func (s *CsvFileModelSource) Format() string {
+	if s.options.Comma == '\t' {
+		return fmt.Sprintf("tsv %q", s.options.Comma)
+	}
+	return fmt.Sprintf("csv %q", s.options.Comma)
+}
+
+// Read the model from the given source.  The formatting of the file, such as the line endings and
+// which fields were quoted, is retained so that it can be reproduced when the model is written.
+func (s *CsvFileModelSource) Read() (Model, error) {
 	// Check if the file exists.  If not, return an empty model
 	if _, err := os.Stat(s.filename); os.IsNotExist(err) {
+		s.format = nil
 		return NewSingleCellStdModel(), nil
 	}
 
-	f, err := os.Open(s.filename)
+	data, err := ioutil.ReadFile(s.filename)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
+
+	format := newCsvFileFormat(string(data))
+	if format.lineEnding == "\r\n" {
+		s.options.UseCRLF = true
+	}
 
 	model := new(StdModel)
-	r := csv.NewReader(f)
-	r.Comma = s.options.Comma
-	r.FieldsPerRecord = -1
-	for {
+	var leading []string
+	for _, raw := range splitCsvRecords(format.content, s.options) {
+		text := trimLineEnding(raw)
+		if text == "" || (s.options.Comment != 0 && strings.HasPrefix(text, string(s.options.Comment))) {
+			leading = append(leading, raw)
+			continue
+		}
+
+		r := csv.NewReader(strings.NewReader(text))
+		r.Comma = s.options.Comma
+		r.LazyQuotes = s.options.LazyQuotes
+		r.TrimLeadingSpace = s.options.TrimLeadingSpace
 		record, err := r.Read()
-		if err == io.EOF {
-			break
-		} else if err != nil {
-			return nil, err
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %v", len(format.records)+1, err)
 		}
 
+		format.addRecord(csvRawRecord{
+			leading: strings.Join(leading, ""),
+			text:    text,
+			values:  record,
+			quoted:  csvQuotedFields(text, s.options),
+		})
+		leading = nil
+
 		model.appendStr(record)
 	}
+	format.trailing = strings.Join(leading, "")
+	format.options = s.options
+	s.format = format
+
+	if len(model.Cells) == 0 {
+		return NewSingleCellStdModel(), nil
+	}
 
 	model.dirty = false
 	return model, nil
 }
 
-func (s CsvFileModelSource) Write(m Model) error {
-	f, err := os.Create(s.filename)
-	if err != nil {
-		return err
-	}
-
-	w := csv.NewWriter(f)
-	w.Comma = s.options.Comma
+// Write the model to the file.  Records which have not changed since the file was read are written as
+// they originally appeared, provided that the options have not changed.  Blank lines and comments are
+// always kept.
+func (s *CsvFileModelSource) Write(m Model) error {
+	return atomicWriteFile(s.filename, func(out io.Writer) error {
+		return s.write(out, m)
+	})
+}
 
+func (s *CsvFileModelSource) write(out io.Writer, m Model) error {
+	w := bufio.NewWriter(out)
 	rows, cols := m.Dimensions()
 
+	format := s.format
+	if format == nil {
+		format = newCsvFileFormat("")
+	}
+	preserve := format.options == s.options
+	lineEnding := "\n"
+	if s.options.UseCRLF {
+		lineEnding = "\r\n"
+	}
+	usedRecords := make(map[int]bool)
+
+	if format.bom {
+		w.WriteString(utf8BOM)
+	}
+
+	record := make([]string, cols)
 	for r := 0; r < rows; r++ {
-		record := make([]string, cols) // Reuse the record slice
 		for c := 0; c < cols; c++ {
 			record[c] = m.CellValue(r, c)
 		}
-		if err := w.Write(record); err != nil {
-			f.Close()
+
+		if r > 0 {
+			w.WriteString(lineEnding)
+		}
+
+		// Blank lines and comments stay at the position they were read from, whether or not the record changed
+		if r < len(format.records) {
+			w.WriteString(replaceLineEndings(format.records[r].leading, lineEnding))
+		}
+
+		if preserve {
+			if idx, found := format.findRecord(r, record, usedRecords); found {
+				usedRecords[idx] = true
+				w.WriteString(format.records[idx].text)
+				continue
+			}
+		}
+
+		if err := s.writeRecord(w, record, format.quotedFields(r, len(record))); err != nil {
 			return err
 		}
 	}
 
-	w.Flush()
-	if err := w.Error(); err != nil {
-		f.Close()
-		return err
+	if rows > 0 && (format.trailingNewline || format.trailing != "") {
+		w.WriteString(lineEnding)
+	}
+	w.WriteString(replaceLineEndings(format.trailing, lineEnding))
+
+	return w.Flush()
+}
+
+// Writes a single record without the line ending.  Fields are quoted if they need to be, if they were
+// quoted originally, or if the QuoteAll option is set.
+func (s *CsvFileModelSource) writeRecord(w *bufio.Writer, record []string, quoted []bool) error {
+	for i, field := range record {
+		if i > 0 {
+			w.WriteRune(s.options.Comma)
+		}
+
+		if s.options.QuoteAll || (i < len(quoted) && quoted[i]) || s.fieldNeedsQuotes(field) {
+			w.WriteByte('"')
+			w.WriteString(strings.ReplaceAll(field, `"`, `""`))
+			w.WriteByte('"')
+		} else {
+			w.WriteString(field)
+		}
+	}
+	return nil
+}
+
+// Returns true if the field needs to be quoted.  This follows the rules used by csv.Writer.
+func (s *CsvFileModelSource) fieldNeedsQuotes(field string) bool {
+	if field == "" {
+		return false
+	}
+	if field == `\.` || strings.ContainsRune(field, s.options.Comma) || strings.ContainsAny(field, "\"\r\n") {
+		return true
+	}
+	if s.options.Comment != 0 && strings.HasPrefix(field, string(s.options.Comment)) {
+		return true
 	}
 
-	return f.Close()
+	r1, _ := utf8.DecodeRuneInString(field)
+	return unicode.IsSpace(r1)
 }
 
 type JiraTableModelSource struct {
 	Filename string
 	Header   bool
+
+	tableText
+
+	// The rows of the table as they originally appeared in the file
+	rows []rawTableRow
 }
 
-func (s JiraTableModelSource) String() string {
+func (s *JiraTableModelSource) String() string {
 	return filepath.Base(s.Filename)
 }
 
-func (JiraTableModelSource) Read() (Model, error) {
-	return nil, errors.New("read not supported yet")
+func (s *JiraTableModelSource) Path() string {
+	return s.Filename
+}
+
+func (s *JiraTableModelSource) Format() string {
+	return "jira"
 }
 
-func (s JiraTableModelSource) Write(m Model) error {
-	f, err := os.Create(s.Filename)
+// Read the model from the source.  The lines before and after the table, and the text of each row, are
+// kept so that they can be written back.  Whether the table has a header is determined from the
+// separators of the first row.
+func (s *JiraTableModelSource) Read() (Model, error) {
+	if _, err := os.Stat(s.Filename); os.IsNotExist(err) {
+		return NewSingleCellStdModel(), nil
+	}
+
+	lines, err := readTextLines(s.Filename)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	model := new(StdModel)
+	rowText := new(strings.Builder)
+	inTable := false
+	tableStart, tableEnd := -1, len(lines)
+	s.rows = nil
+
+	addRow := func() {
+		row := rawTableRow{text: rowText.String()}
+		row.values = parseJiraTableRow(row.text)
+		s.rows = append(s.rows, row)
+		model.appendStr(row.values)
+	}
+
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "|") {
+			if inTable {
+				addRow()
+			} else {
+				s.Header = isJiraHeaderRow(line)
+				tableStart = i
+				inTable = true
+			}
+			rowText.Reset()
+			rowText.WriteString(line)
+		} else if inTable && strings.TrimSpace(line) != "" {
+			// A row which spans multiple lines
+			rowText.WriteString("\n")
+			rowText.WriteString(line)
+		} else if inTable {
+			// The table has ended
+			tableEnd = i
+			break
+		}
+	}
+
+	s.keepLines(lines, tableStart, tableEnd)
+	if !inTable {
+		return NewSingleCellStdModel(), nil
+	}
+	addRow()
+
+	model.dirty = false
+	return model, nil
+}
+
+// Parses a single row of a Jira table.  Separators within links and macros, or escaped with a
+// backslash, are not treated as cell boundaries.
+func parseJiraTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+
+	cells := make([]string, 0)
+	cell := new(strings.Builder)
+	markup := new(jiraMarkupState)
+	started := false
+
+	for i := 0; i < len(line); {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|' && !markup.isProtected():
+			cell.WriteByte('|')
+			i += 2
+		case line[i] == '|' && !markup.isProtected():
+			if started {
+				cells = append(cells, strings.TrimSpace(cell.String()))
+			}
+			cell.Reset()
+			started = true
+
+			// Header separators are two pipes wide
+			i++
+			if i < len(line) && line[i] == '|' {
+				i++
+			}
+		default:
+			n := markup.advance(line, i)
+			cell.WriteString(line[i : i+n])
+			i += n
+		}
+	}
+
+	// Rows which are missing the trailing separator
+	if rest := strings.TrimSpace(cell.String()); rest != "" {
+		cells = append(cells, rest)
+	}
+	return cells
+}
+
+// Escapes any separators in the cell which are not within a link or macro.
+func escapeJiraTableCell(value string) string {
+	if !strings.Contains(value, "|") {
+		return value
+	}
+
+	sb := new(strings.Builder)
+	markup := new(jiraMarkupState)
+	for i := 0; i < len(value); {
+		if value[i] == '|' && !markup.isProtected() {
+			sb.WriteString("\\|")
+			i++
+			continue
+		}
+
+		n := markup.advance(value, i)
+		sb.WriteString(value[i : i+n])
+		i += n
+	}
+	return sb.String()
+}
+
+// Macros which enclose content between an opening and closing tag
+var pairedJiraMacros = map[string]bool{
+	"code":     true,
+	"color":    true,
+	"noformat": true,
+	"panel":    true,
+	"quote":    true,
+}
+
+// Tracks whether the text being scanned is within a link or macro, where separators are treated
+// as part of the cell content.
+type jiraMarkupState struct {
+	linkDepth int
+	macros    []string
+}
+
+func (st *jiraMarkupState) isProtected() bool {
+	return st.linkDepth > 0 || len(st.macros) > 0
+}
+
+// Advances over the markup at position i of str.  Returns the number of bytes consumed.
+func (st *jiraMarkupState) advance(str string, i int) int {
+	switch str[i] {
+	case '[':
+		st.linkDepth++
+	case ']':
+		if st.linkDepth > 0 {
+			st.linkDepth--
+		}
+	case '{':
+		tagLen := strings.IndexByte(str[i:], '}')
+		if tagLen < 0 {
+			return 1
+		}
+
+		tag := str[i+1 : i+tagLen]
+		name := strings.SplitN(tag, ":", 2)[0]
+		if n := len(st.macros); n > 0 && st.macros[n-1] == name && tag == name {
+			st.macros = st.macros[:n-1]
+		} else if pairedJiraMacros[name] {
+			st.macros = append(st.macros, name)
+		}
+		return tagLen + 1
 	}
-	defer f.Close()
+	return 1
+}
 
+func (s *JiraTableModelSource) Write(m Model) error {
+	return atomicWriteFile(s.Filename, func(w io.Writer) error {
+		return s.write(w, m)
+	})
+}
+
+// Writes the table.  Rows which have not changed since the table was read are written as they originally
+// appeared.  Other rows keep the number of cells they were read with, unless cells beyond them were set.
+func (s *JiraTableModelSource) write(w io.Writer, m Model) error {
 	rows, cols := m.Dimensions()
 	line := new(strings.Builder)
+	usedRows := make(map[int]bool)
+
+	if err := writeTextLines(w, s.before); err != nil {
+		return err
+	}
 
+	values := make([]string, cols)
 	for r := 0; r < rows; r++ {
+		for c := range values {
+			values[c] = m.CellValue(r, c)
+		}
+
+		header := r == 0 && s.Header
+		idx, found := findRawTableRow(s.rows, r, values, usedRows, func(idx int) bool {
+			return isJiraHeaderRow(s.rows[idx].text) == header
+		})
+		if found {
+			usedRows[idx] = true
+			if _, err := fmt.Fprintln(w, s.rows[idx].text); err != nil {
+				return err
+			}
+			continue
+		}
+
+		cellCount := cols
+		if r < len(s.rows) {
+			cellCount = minInt(len(s.rows[r].values), cols)
+		}
+		for c := cellCount; c < cols; c++ {
+			if values[c] != "" {
+				cellCount = c + 1
+			}
+		}
+
 		sep := "|"
-		if r == 0 && s.Header {
+		if header {
 			sep = "||"
 		}
 
@@ -145,21 +515,341 @@ func (s JiraTableModelSource) Write(m Model) error {
 		line.WriteString(sep)
 		line.WriteRune(' ')
 
-		for c := 0; c < cols; c++ {
+		for c := 0; c < maxInt(cellCount, 1); c++ {
 			if c >= 1 {
 				line.WriteRune(' ')
 				line.WriteString(sep)
 				line.WriteRune(' ')
 			}
-			line.WriteString(m.CellValue(r, c))
+			line.WriteString(escapeJiraTableCell(values[c]))
 		}
 
 		line.WriteRune(' ')
 		line.WriteString(sep)
-		if _, err := fmt.Fprintln(f, line.String()); err != nil {
+		if _, err := fmt.Fprintln(w, line.String()); err != nil {
 			return err
 		}
 	}
 
-	return nil
+	return writeTextLines(w, s.after)
+}
+
+// Returns true if the row is a header row, which uses double separators
+func isJiraHeaderRow(text string) bool {
+	return strings.HasPrefix(strings.TrimSpace(text), "||")
+}
+
+// The alignment of a column within a markdown table
+type MarkdownAlignment int
+
+const (
+	MarkdownAlignNone MarkdownAlignment = iota
+	MarkdownAlignLeft
+	MarkdownAlignCenter
+	MarkdownAlignRight
+)
+
+// A model source backed by a GitHub-flavoured markdown table.  The first row of the model is the
+// table header.
+type MarkdownTableModelSource struct {
+	Filename string
+
+	// The alignment of each column.  This is set when the table is read.
+	Alignments []MarkdownAlignment
+
+	// Pad the cells so that the columns line up when written.  Otherwise, rows which have not changed
+	// since the table was read are written as they originally appeared.
+	Pad bool
+
+	tableText
+
+	// The header and body rows, and the delimiter row, as they originally appeared in the file
+	rows      []rawTableRow
+	delimiter string
+}
+
+var markdownDelimiterCellPattern = regexp.MustCompile(`^:?-+:?$`)
+
+var markdownLineBreakPattern = regexp.MustCompile(`(?i)<br\s*/?>`)
+
+func (s *MarkdownTableModelSource) String() string {
+	return filepath.Base(s.Filename)
+}
+
+func (s *MarkdownTableModelSource) Path() string {
+	return s.Filename
+}
+
+func (s *MarkdownTableModelSource) Format() string {
+	return "markdown"
+}
+
+// Read the model from the source.  Only the first table within the file is read, with the lines before
+// and after it kept so that they can be written back.
+func (s *MarkdownTableModelSource) Read() (Model, error) {
+	if _, err := os.Stat(s.Filename); os.IsNotExist(err) {
+		return NewSingleCellStdModel(), nil
+	}
+
+	lines, err := readTextLines(s.Filename)
+	if err != nil {
+		return nil, err
+	}
+
+	model := new(StdModel)
+	tableStart, tableEnd := -1, len(lines)
+	s.rows, s.delimiter = nil, ""
+
+	var prevRow []string
+	prevRowLine := 0
+
+	for i, rawLine := range lines {
+		line := strings.TrimSpace(rawLine)
+		isTableLine := strings.Contains(line, "|")
+
+		if model.Cells != nil {
+			// Within the table body
+			if !isTableLine {
+				tableEnd = i
+				break
+			}
+			row := rawTableRow{text: rawLine, values: parseMarkdownTableRow(line)}
+			s.rows = append(s.rows, row)
+			model.appendStr(row.values)
+			continue
+		}
+
+		// Look for a header row followed by a delimiter row
+		if !isTableLine {
+			prevRow = nil
+			continue
+		}
+
+		row := parseMarkdownTableRow(line)
+		if alignments, isDelimiter := parseMarkdownDelimiterRow(row); isDelimiter && prevRow != nil && len(prevRow) == len(row) {
+			s.Alignments = alignments
+			tableStart = prevRowLine
+			s.rows = append(s.rows, rawTableRow{text: lines[prevRowLine], values: prevRow})
+			s.delimiter = rawLine
+			model.appendStr(prevRow)
+		} else {
+			prevRow, prevRowLine = row, i
+		}
+	}
+
+	s.keepLines(lines, tableStart, tableEnd)
+	if model.Cells == nil {
+		return NewSingleCellStdModel(), nil
+	}
+
+	model.dirty = false
+	return model, nil
+}
+
+// Write writes the model as a markdown table, along with the text around it
+func (s *MarkdownTableModelSource) Write(m Model) error {
+	return atomicWriteFile(s.Filename, func(w io.Writer) error {
+		return s.write(w, m)
+	})
+}
+
+func (s *MarkdownTableModelSource) write(out io.Writer, m Model) error {
+	rows, cols := m.Dimensions()
+
+	values := make([][]string, rows)
+	cells := make([][]string, rows)
+	colWidths := make([]int, cols)
+	for c := range colWidths {
+		colWidths[c] = 3
+	}
+
+	for r := 0; r < rows; r++ {
+		values[r] = make([]string, cols)
+		cells[r] = make([]string, cols)
+		for c := 0; c < cols; c++ {
+			values[r][c] = m.CellValue(r, c)
+			cells[r][c] = escapeMarkdownTableCell(values[r][c])
+			if w := runewidth.StringWidth(cells[r][c]); w > colWidths[c] {
+				colWidths[c] = w
+			}
+		}
+	}
+
+	delimiters := make([]string, cols)
+	for c := range delimiters {
+		delimiters[c] = s.delimiterCell(c, colWidths[c])
+	}
+
+	line := new(strings.Builder)
+	writeRow := func(row []string) error {
+		line.Reset()
+		line.WriteString("|")
+		for c, cell := range row {
+			line.WriteRune(' ')
+			if s.Pad {
+				line.WriteString(s.padCell(c, cell, colWidths[c]))
+			} else {
+				line.WriteString(cell)
+			}
+			line.WriteString(" |")
+		}
+		_, err := fmt.Fprintln(out, line.String())
+		return err
+	}
+
+	if err := writeTextLines(out, s.before); err != nil {
+		return err
+	}
+
+	usedRows := make(map[int]bool)
+	for r := 0; r < rows; r++ {
+		var err error
+		idx, found := 0, false
+		if !s.Pad {
+			// The header row is only matched with the original header row
+			idx, found = findRawTableRow(s.rows, r, values[r], usedRows, func(idx int) bool { return (idx == 0) == (r == 0) })
+		}
+		if found {
+			usedRows[idx] = true
+			_, err = fmt.Fprintln(out, s.rows[idx].text)
+		} else {
+			err = writeRow(cells[r])
+		}
+		if err != nil {
+			return err
+		}
+
+		if r == 0 {
+			if !s.Pad && s.delimiterUnchanged(cols) {
+				_, err = fmt.Fprintln(out, s.delimiter)
+			} else {
+				err = writeRow(delimiters)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return writeTextLines(out, s.after)
+}
+
+// Returns true if the original delimiter row can be written for a table with the number of columns
+func (s *MarkdownTableModelSource) delimiterUnchanged(cols int) bool {
+	if s.delimiter == "" {
+		return false
+	}
+
+	alignments, _ := parseMarkdownDelimiterRow(parseMarkdownTableRow(s.delimiter))
+	if len(alignments) != cols {
+		return false
+	}
+	for c, alignment := range alignments {
+		if alignment != s.alignment(c) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *MarkdownTableModelSource) alignment(col int) MarkdownAlignment {
+	if col < len(s.Alignments) {
+		return s.Alignments[col]
+	}
+	return MarkdownAlignNone
+}
+
+func (s *MarkdownTableModelSource) delimiterCell(col int, width int) string {
+	if !s.Pad {
+		width = 3
+	}
+
+	switch s.alignment(col) {
+	case MarkdownAlignLeft:
+		return ":" + strings.Repeat("-", width-1)
+	case MarkdownAlignCenter:
+		return ":" + strings.Repeat("-", width-2) + ":"
+	case MarkdownAlignRight:
+		return strings.Repeat("-", width-1) + ":"
+	default:
+		return strings.Repeat("-", width)
+	}
+}
+
+func (s *MarkdownTableModelSource) padCell(col int, cell string, width int) string {
+	padding := width - runewidth.StringWidth(cell)
+	if padding <= 0 {
+		return cell
+	}
+
+	switch s.alignment(col) {
+	case MarkdownAlignRight:
+		return strings.Repeat(" ", padding) + cell
+	case MarkdownAlignCenter:
+		return strings.Repeat(" ", padding/2) + cell + strings.Repeat(" ", padding-padding/2)
+	default:
+		return cell + strings.Repeat(" ", padding)
+	}
+}
+
+// Parses a single row of a markdown table.  The leading and trailing pipes are optional, and line
+// breaks within cells are read as newlines.
+func parseMarkdownTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+
+	cells := make([]string, 0)
+	cell := new(strings.Builder)
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, unescapeMarkdownTableCell(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+
+	// Rows which are missing the trailing pipe
+	if rest := unescapeMarkdownTableCell(cell.String()); rest != "" || len(cells) == 0 {
+		cells = append(cells, rest)
+	}
+	return cells
+}
+
+// Parses the delimiter row separating the table header from the body.  Returns false if the
+// row is not a delimiter row.
+func parseMarkdownDelimiterRow(row []string) ([]MarkdownAlignment, bool) {
+	alignments := make([]MarkdownAlignment, len(row))
+	for c, cell := range row {
+		if !markdownDelimiterCellPattern.MatchString(cell) {
+			return nil, false
+		}
+
+		leftColon, rightColon := strings.HasPrefix(cell, ":"), strings.HasSuffix(cell, ":")
+		switch {
+		case leftColon && rightColon:
+			alignments[c] = MarkdownAlignCenter
+		case leftColon:
+			alignments[c] = MarkdownAlignLeft
+		case rightColon:
+			alignments[c] = MarkdownAlignRight
+		}
+	}
+	return alignments, true
+}
+
+// Escapes the pipes within a cell.  Newlines, which cannot appear within a table, are written as
+// line breaks.
+func escapeMarkdownTableCell(value string) string {
+	value = strings.ReplaceAll(value, "|", "\\|")
+	return strings.ReplaceAll(value, "\n", "<br>")
+}
+
+// Reverses the escaping of a cell done by escapeMarkdownTableCell, other than that of pipes
+func unescapeMarkdownTableCell(cell string) string {
+	return markdownLineBreakPattern.ReplaceAllString(strings.TrimSpace(cell), "\n")
 }
@@ -0,0 +1,467 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCsvFileModelSource_Read(t *testing.T) {
+	t.Run("should read using the dialect options", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", ""+
+			"# a comment\n"+
+			"a; b;c\n"+
+			"1;x \"y\" z;3\n")
+
+		source := NewCsvFileModelSource(filename, CsvFileModelSourceOptions{
+			Comma:            ';',
+			Comment:          '#',
+			LazyQuotes:       true,
+			TrimLeadingSpace: true,
+		})
+		model, err := source.Read()
+		assert.NoError(t, err)
+
+		assertModel(t, model, [][]string{
+			{"a", "b", "c"},
+			{"1", "x \"y\" z", "3"},
+		})
+	})
+
+	t.Run("should not join records following a quote within a field", func(t *testing.T) {
+		content := "a,5\" screen,b\n\"c \"\"d\"\"\",\"e\nf\",g\n\"5\" wide\",h,i\n"
+		filename := writeTestFile(t, "data.csv", content)
+
+		source := NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ',', LazyQuotes: true})
+		model, err := source.Read()
+		assert.NoError(t, err)
+
+		assertModel(t, model, [][]string{
+			{"a", "5\" screen", "b"},
+			{"c \"d\"", "e\nf", "g"},
+			{"5\" wide", "h", "i"},
+		})
+
+		assert.NoError(t, source.Write(model))
+		assertFileContent(t, filename, content)
+	})
+
+	t.Run("should report a quote within a field without lazy quotes", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "a,b\nc,5\" screen\nd,e\n")
+
+		_, err := NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ','}).Read()
+		assert.EqualError(t, err, "record 2: parse error on line 1, column 4: bare \" in non-quoted-field")
+	})
+}
+
+func TestSplitCsvRecords(t *testing.T) {
+	scenarios := []struct {
+		content  string
+		options  CsvFileModelSourceOptions
+		expected []string
+	}{
+		{"a,b\nc,d", CsvFileModelSourceOptions{Comma: ','}, []string{"a,b\n", "c,d"}},
+		{"a,\"b\nc\"\r\nd\n", CsvFileModelSourceOptions{Comma: ','}, []string{"a,\"b\nc\"\r\n", "d\n"}},
+		{"a,\"b\"\"\nc\"\nd\n", CsvFileModelSourceOptions{Comma: ','}, []string{"a,\"b\"\"\nc\"\n", "d\n"}},
+		{"a,5\" b\nc\n", CsvFileModelSourceOptions{Comma: ','}, []string{"a,5\" b\n", "c\n"}},
+		{"\"5\" b\",c\nd\n", CsvFileModelSourceOptions{Comma: ',', LazyQuotes: true}, []string{"\"5\" b\",c\n", "d\n"}},
+		{"a; \"b;\nc\"\nd\n", CsvFileModelSourceOptions{Comma: ';', TrimLeadingSpace: true}, []string{"a; \"b;\nc\"\n", "d\n"}},
+		{"# it's \"\na\n", CsvFileModelSourceOptions{Comma: ',', Comment: '#'}, []string{"# it's \"\n", "a\n"}},
+		{"\n\na\n", CsvFileModelSourceOptions{Comma: ','}, []string{"\n", "\n", "a\n"}},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.content, func(t *testing.T) {
+			assert.Equal(t, scenario.expected, splitCsvRecords(scenario.content, scenario.options))
+		})
+	}
+}
+
+func TestCsvFileModelSource_Write(t *testing.T) {
+	model := NewStdModelFromSlice([][]string{
+		{"a", "b c", ""},
+		{"1,2", "say \"hi\"", " 3"},
+	})
+
+	scenarios := []struct {
+		name     string
+		options  CsvFileModelSourceOptions
+		expected string
+	}{
+		{
+			name:     "should quote minimally",
+			options:  CsvFileModelSourceOptions{Comma: ','},
+			expected: "a,b c,\n\"1,2\",\"say \"\"hi\"\"\",\" 3\"\n",
+		},
+		{
+			name:     "should quote all fields",
+			options:  CsvFileModelSourceOptions{Comma: ',', QuoteAll: true},
+			expected: "\"a\",\"b c\",\"\"\n\"1,2\",\"say \"\"hi\"\"\",\" 3\"\n",
+		},
+		{
+			name:     "should write CRLF line endings",
+			options:  CsvFileModelSourceOptions{Comma: ';', UseCRLF: true},
+			expected: "a;b c;\r\n1,2;\"say \"\"hi\"\"\";\" 3\"\r\n",
+		},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "data.csv")
+			assert.NoError(t, NewCsvFileModelSource(filename, scenario.options).Write(model))
+
+			content, err := ioutil.ReadFile(filename)
+			assert.NoError(t, err)
+			assert.Equal(t, scenario.expected, string(content))
+		})
+	}
+}
+
+func TestCsvFileModelSource_PreservesFormatting(t *testing.T) {
+	original := utf8BOM +
+		"\"name\",\"count\"\r\n" +
+		"# a comment\r\n" +
+		"alpha,  1\r\n" +
+		"\"bravo\",2\r\n" +
+		"\"charlie\",3"
+
+	readModel := func(t *testing.T) (*CsvFileModelSource, *StdModel) {
+		filename := writeTestFile(t, "data.csv", original)
+		source := NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ',', Comment: '#'})
+
+		model, err := source.Read()
+		assert.NoError(t, err)
+		return source, model.(*StdModel)
+	}
+
+	t.Run("should write an unchanged model identically", func(t *testing.T) {
+		source, model := readModel(t)
+		assert.NoError(t, source.Write(model))
+
+		assertFileContent(t, source.filename, original)
+	})
+
+	t.Run("should only reformat changed records", func(t *testing.T) {
+		source, model := readModel(t)
+		model.SetCellValue(2, 1, "22")
+
+		assert.NoError(t, source.Write(model))
+		assertFileContent(t, source.filename, utf8BOM+
+			"\"name\",\"count\"\r\n"+
+			"# a comment\r\n"+
+			"alpha,  1\r\n"+
+			"\"bravo\",22\r\n"+
+			"\"charlie\",3")
+	})
+
+	t.Run("should keep the comments above a changed record", func(t *testing.T) {
+		filename := writeTestFile(t, "data.csv", "a,b\n# comment\n\nc,d\n")
+		source := NewCsvFileModelSource(filename, CsvFileModelSourceOptions{Comma: ',', Comment: '#'})
+		model, err := source.Read()
+		assert.NoError(t, err)
+
+		model.(*StdModel).SetCellValue(1, 0, "x")
+
+		assert.NoError(t, source.Write(model))
+		assertFileContent(t, filename, "a,b\n# comment\n\nx,d\n")
+	})
+
+	t.Run("should keep records unchanged when rows are deleted", func(t *testing.T) {
+		source, model := readModel(t)
+		mvc := NewGridViewModel(model)
+		assert.NoError(t, mvc.DeleteRow(2))
+
+		assert.NoError(t, source.Write(model))
+		assertFileContent(t, source.filename, utf8BOM+
+			"\"name\",\"count\"\r\n"+
+			"# a comment\r\n"+
+			"alpha,  1\r\n"+
+			"\"charlie\",3")
+	})
+
+	t.Run("should reformat all records when the options change", func(t *testing.T) {
+		source, model := readModel(t)
+		opts := source.Options()
+		opts.UseCRLF = false
+		source.SetOptions(opts)
+
+		assert.NoError(t, source.Write(model))
+		assertFileContent(t, source.filename, utf8BOM+
+			"\"name\",\"count\"\n"+
+			"# a comment\n"+
+			"alpha,\"  1\"\n"+
+			"\"bravo\",2\n"+
+			"\"charlie\",3")
+	})
+}
+
+func TestJiraTableModelSource_Read(t *testing.T) {
+	t.Run("should read header and data rows", func(t *testing.T) {
+		filename := writeTestFile(t, "table.jira", ""+
+			"||letters||numbers||\n"+
+			"| a | 1 |\n"+
+			"| b | 2 |\n")
+
+		source := &JiraTableModelSource{Filename: filename}
+		model, err := source.Read()
+		assert.NoError(t, err)
+		assert.True(t, source.Header)
+
+		assertModel(t, model, [][]string{
+			{"letters", "numbers"},
+			{"a", "1"},
+			{"b", "2"},
+		})
+	})
+
+	t.Run("should not split on escaped pipes, links or macros", func(t *testing.T) {
+		filename := writeTestFile(t, "table.jira", ""+
+			"| a \\| b | [link|http://example.com/] |\n"+
+			"| {color:red}x|y{color} | c |\n")
+
+		source := &JiraTableModelSource{Filename: filename}
+		model, err := source.Read()
+		assert.NoError(t, err)
+		assert.False(t, source.Header)
+
+		assertModel(t, model, [][]string{
+			{"a | b", "[link|http://example.com/]"},
+			{"{color:red}x|y{color}", "c"},
+		})
+	})
+
+	t.Run("should write ragged rows and rows spanning multiple lines back unchanged", func(t *testing.T) {
+		content := "" +
+			"Some text before the table\n" +
+			"||a||b||c||\n" +
+			"|1|2|\n" +
+			"| 3 | multi\n" +
+			"line | 4 |\n" +
+			"\n" +
+			"Some text after the table\n"
+		filename := writeTestFile(t, "table.jira", content)
+
+		source := &JiraTableModelSource{Filename: filename}
+		model, err := source.Read()
+		assert.NoError(t, err)
+
+		assertModel(t, model, [][]string{
+			{"a", "b", "c"},
+			{"1", "2", ""},
+			{"3", "multi\nline", "4"},
+		})
+
+		assert.NoError(t, source.Write(model))
+		assertFileContent(t, filename, content)
+	})
+
+	t.Run("should keep the cell count of changed rows", func(t *testing.T) {
+		filename := writeTestFile(t, "table.jira", ""+
+			"||a||b||c||\n"+
+			"|1|2|\n"+
+			"|3|4|\n"+
+			"|5|6|7|\n")
+
+		source := &JiraTableModelSource{Filename: filename}
+		model, err := source.Read()
+		assert.NoError(t, err)
+
+		stdModel := model.(*StdModel)
+		stdModel.SetCellValue(1, 0, "x")
+		stdModel.SetCellValue(2, 2, "y")
+		assert.NoError(t, NewGridViewModel(stdModel).DeleteRow(3))
+
+		assert.NoError(t, source.Write(model))
+		assertFileContent(t, filename, ""+
+			"||a||b||c||\n"+
+			"| x | 2 |\n"+
+			"| 3 | 4 | y |\n")
+	})
+
+	t.Run("should read back a written model unchanged", func(t *testing.T) {
+		expected := [][]string{
+			{"name", "link"},
+			{"a|b", "[x|http://example.com/]"},
+			{"", "{code}c|d{code}"},
+		}
+		filename := filepath.Join(t.TempDir(), "table.jira")
+
+		source := &JiraTableModelSource{Filename: filename, Header: true}
+		assert.NoError(t, source.Write(NewStdModelFromSlice(expected)))
+
+		model, err := (&JiraTableModelSource{Filename: filename}).Read()
+		assert.NoError(t, err)
+		assertModel(t, model, expected)
+	})
+
+	t.Run("should keep the text around the table when written", func(t *testing.T) {
+		content := "" +
+			"h1. Heading\n" +
+			"Some text before the table\n" +
+			"|| a || b ||\n" +
+			"| 1 | 2 |\n" +
+			"\n" +
+			"Some text after the table\n" +
+			"| 3 | 4 |\n"
+		filename := writeTestFile(t, "table.jira", content)
+
+		source := &JiraTableModelSource{Filename: filename}
+		model, err := source.Read()
+		assert.NoError(t, err)
+		assertModel(t, model, [][]string{
+			{"a", "b"},
+			{"1", "2"},
+		})
+
+		assert.NoError(t, source.Write(model))
+		assertFileContent(t, filename, content)
+	})
+}
+
+func TestMarkdownTableModelSource_Read(t *testing.T) {
+	t.Run("should read the first table and its alignments", func(t *testing.T) {
+		filename := writeTestFile(t, "table.md", ""+
+			"# Heading\n"+
+			"\n"+
+			"| name | count | notes |\n"+
+			"|:-----|------:|:-----:|\n"+
+			"| a \\| b | 1 | x |\n"+
+			"c | 2\n"+
+			"\n"+
+			"| other | table |\n"+
+			"|---|---|\n")
+
+		source := &MarkdownTableModelSource{Filename: filename}
+		model, err := source.Read()
+		assert.NoError(t, err)
+
+		assert.Equal(t, []MarkdownAlignment{MarkdownAlignLeft, MarkdownAlignRight, MarkdownAlignCenter}, source.Alignments)
+		assertModel(t, model, [][]string{
+			{"name", "count", "notes"},
+			{"a | b", "1", "x"},
+			{"c", "2", ""},
+		})
+	})
+}
+
+func TestMarkdownTableModelSource_Write(t *testing.T) {
+	model := NewStdModelFromSlice([][]string{
+		{"name", "count"},
+		{"a|b", "1"},
+		{"longer", "100"},
+	})
+
+	t.Run("should write padded columns", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "table.md")
+		source := &MarkdownTableModelSource{
+			Filename:   filename,
+			Alignments: []MarkdownAlignment{MarkdownAlignNone, MarkdownAlignRight},
+			Pad:        true,
+		}
+		assert.NoError(t, source.Write(model))
+
+		content, err := ioutil.ReadFile(filename)
+		assert.NoError(t, err)
+		assert.Equal(t, ""+
+			"| name   | count |\n"+
+			"| ------ | ----: |\n"+
+			"| a\\|b   |     1 |\n"+
+			"| longer |   100 |\n", string(content))
+	})
+
+	t.Run("should read back a written model unchanged", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "table.md")
+		source := &MarkdownTableModelSource{
+			Filename:   filename,
+			Alignments: []MarkdownAlignment{MarkdownAlignCenter, MarkdownAlignLeft},
+		}
+		assert.NoError(t, source.Write(model))
+
+		readSource := &MarkdownTableModelSource{Filename: filename}
+		readModel, err := readSource.Read()
+		assert.NoError(t, err)
+		assertModel(t, readModel, [][]string{
+			{"name", "count"},
+			{"a|b", "1"},
+			{"longer", "100"},
+		})
+		assert.Equal(t, source.Alignments, readSource.Alignments)
+	})
+
+	t.Run("should keep the text around the table and the line breaks within cells", func(t *testing.T) {
+		content := "" +
+			"# Heading\n" +
+			"\n" +
+			"Some text before the table.\n" +
+			"\n" +
+			"| name | notes |\n" +
+			"| --- | --- |\n" +
+			"| a | one<br>two |\n" +
+			"\n" +
+			"Some text after the table.\n"
+		filename := writeTestFile(t, "table.md", content)
+
+		source := &MarkdownTableModelSource{Filename: filename}
+		model, err := source.Read()
+		assert.NoError(t, err)
+		assertModel(t, model, [][]string{
+			{"name", "notes"},
+			{"a", "one\ntwo"},
+		})
+
+		assert.NoError(t, source.Write(model))
+		assertFileContent(t, filename, content)
+	})
+
+	t.Run("should keep the layout of unchanged rows unless padding", func(t *testing.T) {
+		content := "" +
+			"|a|b|\n" +
+			"|---|--:|\n" +
+			"|1|2|\n" +
+			"|3|4|\n"
+
+		scenarios := []struct {
+			pad      bool
+			expected string
+		}{
+			{false, "|a|b|\n|---|--:|\n|1|2|\n| x | 4 |\n"},
+			{true, "| a   |   b |\n| --- | --: |\n| 1   |   2 |\n| x   |   4 |\n"},
+		}
+
+		for _, scenario := range scenarios {
+			t.Run(fmt.Sprintf("pad=%v", scenario.pad), func(t *testing.T) {
+				filename := writeTestFile(t, "table.md", content)
+				source := &MarkdownTableModelSource{Filename: filename, Pad: scenario.pad}
+				model, err := source.Read()
+				assert.NoError(t, err)
+
+				model.(*StdModel).SetCellValue(2, 0, "x")
+
+				assert.NoError(t, source.Write(model))
+				assertFileContent(t, filename, scenario.expected)
+			})
+		}
+	})
+
+	t.Run("should write the table after the text of a file without one", func(t *testing.T) {
+		filename := writeTestFile(t, "table.md", "# Heading\n")
+
+		source := &MarkdownTableModelSource{Filename: filename}
+		_, err := source.Read()
+		assert.NoError(t, err)
+
+		assert.NoError(t, source.Write(NewStdModelFromSlice([][]string{{"a"}, {"b"}})))
+		assertFileContent(t, filename, "# Heading\n\n| a |\n| --- |\n| b |\n")
+	})
+}
+
+func writeTestFile(t *testing.T, name string, content string) string {
+	filename := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// The delimiters of the codecs which cells can be piped through external commands as
+var pipeCodecs = map[string]rune{
+	"csv": ',',
+	"tsv": '\t',
+}
+
+const defaultPipeCodec = "tsv"
+
+// Characters which have a special meaning to the shell
+const shellSpecialChars = " \t\r\n'\"\\$`|&;<>()*?[]{}~#!"
+
+// Runs a command using the shell, with the input written to its standard input.  Returns the standard
+// output and standard error of the command.  If the command cannot be run or exits with a non-zero status,
+// an error is returned which includes the first line of standard error.
+func runShellCommand(command string, input string) (stdout string, stderr string, err error) {
+	var outBuf, errBuf bytes.Buffer
+
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = strings.NewReader(input)
+	cmd.Stdout = &outBuf
+	cmd.Stderr = &errBuf
+
+	if err := cmd.Run(); err != nil {
+		if msg := firstLine(errBuf.String()); msg != "" {
+			return "", "", fmt.Errorf("%v (%v)", msg, err)
+		}
+		return "", "", err
+	}
+	return outBuf.String(), errBuf.String(), nil
+}
+
+// Returns the shell command of arguments.  A single argument is taken to be the command itself, so that a
+// quoted command is passed to the shell as it is.  Otherwise, the arguments are joined, quoting those
+// which are not shell operators, such as "|" or ">", so that each is read by the shell as a single word.
+func shellCommandFromArgs(args []string) string {
+	if len(args) == 1 {
+		return args[0]
+	}
+
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = quoteShellArg(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// Quotes an argument for the shell, unless it is a shell operator or has no characters special to the shell
+func quoteShellArg(arg string) string {
+	if arg != "" && (strings.Trim(arg, "|&<>") == "" || !strings.ContainsAny(arg, shellSpecialChars)) {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// Encodes records as lines of fields separated by the delimiter, quoting fields as CSV does
+func encodePipeRecords(records [][]string, comma rune) (string, error) {
+	var sb strings.Builder
+	w := csv.NewWriter(&sb)
+	w.Comma = comma
+	if err := w.WriteAll(records); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// Decodes the output of a command into records.  Unlike reading a CSV file, blank lines are taken to be
+// records with a single empty field, so that empty cells piped through a command are kept.
+func decodePipeRecords(data string, comma rune) ([][]string, error) {
+	records := make([][]string, 0)
+	for _, raw := range splitCsvRecords(data, CsvFileModelSourceOptions{Comma: comma, LazyQuotes: true}) {
+		text := trimLineEnding(raw)
+		if text == "" {
+			records = append(records, []string{""})
+			continue
+		}
+
+		r := csv.NewReader(strings.NewReader(text))
+		r.Comma = comma
+		r.LazyQuotes = true
+		record, err := r.Read()
+		if err != nil {
+			return nil, fmt.Errorf("output line %d: %v", len(records)+1, err)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// Pipes the values of the range through a shell command, replacing them with the output.  A single cell
+// is piped as it is, while larger ranges are encoded as records using the comma.  Rows hidden by a filter
+// are left out.  Returns the standard error of the command, which may have written warnings while still
+// succeeding.
+func pipeRange(ctx *CommandContext, cellRange CellRange, command string, comma rune) (string, error) {
+	model := ctx.ModelVC().Model()
+	rows := ctx.ModelVC().VisibleRows(cellRange.Row1, cellRange.Row2)
+	_, cols := cellRange.Dimensions()
+
+	if len(rows) == 1 && cols == 1 {
+		value := model.CellValue(rows[0], cellRange.Col1)
+		if value != "" && !strings.HasSuffix(value, "\n") {
+			value += "\n"
+		}
+
+		stdout, stderr, err := runShellCommand(command, value)
+		if err != nil {
+			return "", err
+		}
+		return stderr, ctx.ModelVC().SetCellValue(rows[0], cellRange.Col1, trimLineEnding(stdout))
+	}
+
+	records := make([][]string, len(rows))
+	for i, r := range rows {
+		records[i] = make([]string, cols)
+		for c := range records[i] {
+			records[i][c] = model.CellValue(r, cellRange.Col1+c)
+		}
+	}
+
+	output, stderr, err := pipeRecords(records, command, comma)
+	if err != nil {
+		return "", err
+	}
+	return stderr, replaceRangeValues(ctx, cellRange, rows, output)
+}
+
+// Pipes all the rows below the header through a shell command, replacing them with the output.  The model
+// is resized to fit the output, so this cannot be done while rows are hidden by a filter.
+func pipeModel(ctx *CommandContext, command string, comma rune) (string, error) {
+	model := ctx.ModelVC().Model()
+	rows, cols := model.Dimensions()
+	if ctx.ModelVC().VisibleRowCount() < rows {
+		return "", errors.New("Cannot pipe all rows while rows are hidden, use unfilter first")
+	}
+
+	headerRows, _ := ctx.ModelVC().Header()
+	headerRows = minInt(headerRows, rows)
+
+	records := make([][]string, rows-headerRows)
+	for r := range records {
+		records[r] = make([]string, cols)
+		for c := range records[r] {
+			records[r][c] = model.CellValue(headerRows+r, c)
+		}
+	}
+
+	output, stderr, err := pipeRecords(records, command, comma)
+	if err != nil {
+		return "", err
+	}
+
+	newCols := 1
+	if headerRows > 0 {
+		newCols = cols
+	}
+	for _, record := range output {
+		newCols = maxInt(newCols, len(record))
+	}
+	newRows := maxInt(headerRows+len(output), 1)
+	if err := ctx.ModelVC().Resize(newRows, newCols); err != nil {
+		return "", err
+	}
+
+	cellRange := CellRange{Row1: headerRows, Col1: 0, Row2: newRows - 1, Col2: newCols - 1}
+	return stderr, replaceRangeValues(ctx, cellRange, ctx.ModelVC().VisibleRows(cellRange.Row1, cellRange.Row2), output)
+}
+
+// Encodes the records, pipes them through the command and decodes the output
+func pipeRecords(records [][]string, command string, comma rune) ([][]string, string, error) {
+	input, err := encodePipeRecords(records, comma)
+	if err != nil {
+		return nil, "", err
+	}
+
+	stdout, stderr, err := runShellCommand(command, input)
+	if err != nil {
+		return nil, "", err
+	}
+
+	output, err := decodePipeRecords(stdout, comma)
+	if err != nil {
+		return nil, "", err
+	}
+	return output, stderr, nil
+}
+
+// Replaces the values of the rows of the range with the records, starting from the first column of the
+// range.  Cells of the rows not covered by the records are cleared.  Records beyond the last of the rows
+// are written to the visible rows following the range, and records beyond the range's columns overwrite
+// the cells beyond it, growing the model if necessary.
+func replaceRangeValues(ctx *CommandContext, cellRange CellRange, rows []int, records [][]string) error {
+	_, rangeCols := cellRange.Dimensions()
+	recordCols := 0
+	for _, record := range records {
+		recordCols = maxInt(recordCols, len(record))
+	}
+
+	targetRows := append([]int{}, rows...)
+	if len(records) > len(rows) {
+		targetRows = append(targetRows, visibleRowsFrom(ctx, cellRange.Row2+1, len(records)-len(rows))...)
+	}
+
+	if len(targetRows) > 0 {
+		if err := growModel(ctx, targetRows[len(targetRows)-1]+1, cellRange.Col1+recordCols); err != nil {
+			return err
+		}
+	}
+
+	for i, r := range targetRows {
+		for c := 0; c < maxInt(rangeCols, recordCols); c++ {
+			value := ""
+			if i < len(records) && c < len(records[i]) {
+				value = records[i][c]
+			} else if i >= len(rows) || c >= rangeCols {
+				continue
+			}
+
+			if err := ctx.ModelVC().SetCellValue(r, cellRange.Col1+c, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the first non-blank line of the text, without surrounding white space
+func firstLine(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
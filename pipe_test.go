@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShellCommandFromArgs(t *testing.T) {
+	scenarios := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"sort -r | uniq"}, "sort -r | uniq"},
+		{[]string{"sort", "-t,", "-k2"}, "sort -t, -k2"},
+		{[]string{"sort", "|", "uniq", "-c", ">", "out.txt"}, "sort | uniq -c > out.txt"},
+		{[]string{"awk", "{print $1}"}, "awk '{print $1}'"},
+		{[]string{"grep", "-v", "a|b"}, "grep -v 'a|b'"},
+		{[]string{"echo", "it's", ""}, `echo 'it'\''s' ''`},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.expected, func(t *testing.T) {
+			assert.Equal(t, scenario.expected, shellCommandFromArgs(scenario.args))
+		})
+	}
+}
+
+func TestPipeRecords(t *testing.T) {
+	t.Run("should encode and decode records", func(t *testing.T) {
+		records := [][]string{{"a", "b c"}, {""}, {"say \"hi\"", "x\ty"}, {"", ""}}
+
+		for _, comma := range pipeCodecs {
+			encoded, err := encodePipeRecords(records, comma)
+			assert.NoError(t, err)
+
+			decoded, err := decodePipeRecords(encoded, comma)
+			assert.NoError(t, err)
+			assert.Equal(t, records, decoded)
+		}
+	})
+
+	t.Run("should decode ragged and unterminated output", func(t *testing.T) {
+		decoded, err := decodePipeRecords("a\tb\nc\n\nd \"e\"", '\t')
+
+		assert.NoError(t, err)
+		assert.Equal(t, [][]string{{"a", "b"}, {"c"}, {""}, {`d "e"`}}, decoded)
+	})
+
+	t.Run("should not join lines following a bare quote", func(t *testing.T) {
+		decoded, err := decodePipeRecords("5\" screen\nabc\ndef\n", '\t')
+
+		assert.NoError(t, err)
+		assert.Equal(t, [][]string{{`5" screen`}, {"abc"}, {"def"}}, decoded)
+	})
+
+	t.Run("should pipe records through a command", func(t *testing.T) {
+		output, stderr, err := pipeRecords([][]string{{"b", "2"}, {"a", "1"}}, "sort; echo warning >&2", ',')
+
+		assert.NoError(t, err)
+		assert.Equal(t, [][]string{{"a", "1"}, {"b", "2"}}, output)
+		assert.Equal(t, "warning\n", stderr)
+	})
+
+	t.Run("should report the standard error of commands which fail", func(t *testing.T) {
+		_, _, err := runShellCommand("echo; echo 'bad things' >&2; echo more >&2; exit 3", "")
+
+		assert.EqualError(t, err, "bad things (exit status 3)")
+	})
+}
@@ -2,10 +2,20 @@ package main
 
 import (
 	"regexp"
+	"time"
 
 	"github.com/lmika/ted/ui"
 )
 
+// The default bounds of column widths set by fitting
+const (
+	defaultMinColWidth = 4
+	defaultMaxColWidth = 40
+)
+
+// The default time to wait for the next key of a key sequence
+const defaultKeyTimeout = time.Second
+
 // The session is responsible for managing the UI and the model and handling
 // the interaction between the two and the user.
 type Session struct {
@@ -13,11 +23,45 @@ type Session struct {
 	Source          ModelSource
 	Frame           *Frame
 	Commands        *CommandMapping
+	Settings        *SettingMapping
 	UIManager       *ui.Ui
 	modelController *ModelViewCtrl
-	pasteBoard		RWModel
+	pasteBoard      RWModel
 
 	LastSearch *regexp.Regexp
+
+	// The history of commands entered at the command prompt
+	CommandHistory *History
+
+	// Keep a backup of the previous version of a file when saving
+	Backup bool
+
+	// The codec cells are piped through shell commands as: "csv" or "tsv"
+	PipeCodec string
+
+	// The bounds of column widths set by fitting, and whether to fit all columns on load
+	MinColWidth, MaxColWidth int
+	FitOnLoad                bool
+
+	// Where the view state of files is kept: ViewStateNone, ViewStateSidecar or ViewStateUser
+	ViewState string
+
+	// The time to wait for the next key of a key sequence, after which the keys typed so far are
+	// invoked if they are mapped, or discarded if they are not
+	KeyTimeout time.Duration
+
+	keyInput keyInput
+
+	// Set when quitting without saving changes, in which case the view state is not saved either
+	forceQuit bool
+}
+
+// The state of a key sequence being typed
+type keyInput struct {
+	count   int    // The count typed before the keys, or 0 if there is none
+	keys    []rune // The keys typed so far
+	timer   *time.Timer
+	timerID int // Identifies the latest timer, so that timeouts of stopped timers are ignored
 }
 
 func NewSession(uiManager *ui.Ui, frame *Frame, source ModelSource) *Session {
@@ -27,15 +71,25 @@ func NewSession(uiManager *ui.Ui, frame *Frame, source ModelSource) *Session {
 		Source:          source,
 		Frame:           frame,
 		Commands:        NewCommandMapping(),
+		Settings:        NewSettingMapping(),
 		UIManager:       uiManager,
 		modelController: NewGridViewModel(model),
-		pasteBoard: 	 NewSingleCellStdModel(),
+		pasteBoard:      NewSingleCellStdModel(),
+		CommandHistory:  &History{},
+		MinColWidth:     defaultMinColWidth,
+		MaxColWidth:     defaultMaxColWidth,
+		ViewState:       ViewStateNone,
+		KeyTimeout:      defaultKeyTimeout,
+		PipeCodec:       defaultPipeCodec,
 	}
 
 	frame.SetModel(&SessionGridModel{session.modelController})
 
 	session.Commands.RegisterViewCommands()
 	session.Commands.RegisterViewKeyBindings()
+	session.Settings.RegisterSessionSettings()
+	session.Settings.RegisterCsvSettings()
+	session.Settings.RegisterMarkdownSettings()
 
 	// Also assign this session with the frame
 	frame.Session = session
@@ -53,34 +107,201 @@ func (session *Session) LoadFromSource() {
 
 	session.model = newModel
 	session.modelController.SetModel(newModel)
+
+	if session.FitOnLoad {
+		session.FitColWidths(0, -1)
+	}
+	if err := session.LoadViewState(); err != nil {
+		session.Frame.Message(err.Error())
+	}
+}
+
+// LoadFromSourceWithSettings loads the model from the source with settings changed, such as by flags.  The
+// settings are changed before the model is loaded, as they can change how it is loaded, and again once it is
+// loaded, so that they take precedence over the restored view state.
+func (session *Session) LoadFromSourceWithSettings(settings map[string]string) error {
+	if err := session.changeSettings(settings); err != nil {
+		return err
+	}
+	session.LoadFromSource()
+	return session.changeSettings(settings)
 }
 
-// Input from the frame
+// Changes the settings to the values
+func (session *Session) changeSettings(settings map[string]string) error {
+	for name, value := range settings {
+		if err := session.Settings.Set(session, name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadViewState restores the view state of the source file, if the view state is kept and the file
+// has been opened before.
+func (session *Session) LoadViewState() error {
+	path, hasPath := session.viewStatePath()
+	if !hasPath {
+		return nil
+	}
+
+	state, err := readViewState(path)
+	if err != nil || state == nil {
+		return err
+	}
+
+	state.apply(session.modelController)
+	session.Frame.Grid().MoveTo(state.CursorCol, state.CursorRow)
+	return nil
+}
+
+// SaveViewStateOnQuit saves the view state once TED has quit, unless it was quit without saving changes.
+func (session *Session) SaveViewStateOnQuit() error {
+	if session.forceQuit {
+		return nil
+	}
+	return session.SaveViewState()
+}
+
+// SaveViewState saves the view state of the source file, if the view state is kept.
+func (session *Session) SaveViewState() error {
+	path, hasPath := session.viewStatePath()
+	if !hasPath {
+		return nil
+	}
+
+	cursorCol, cursorRow := session.Frame.Grid().CellPosition()
+	return writeViewState(path, captureViewState(session.modelController, cursorRow, cursorCol))
+}
+
+// Returns the path of the view state of the source file.  Returns false if the view state is not kept,
+// or the source is not a file.
+func (session *Session) viewStatePath() (string, bool) {
+	fileSource, isFileSource := session.Source.(FileModelSource)
+	if session.ViewState == ViewStateNone || !isFileSource {
+		return "", false
+	}
+
+	path, err := viewStatePath(fileSource.Path(), session.ViewState)
+	if err != nil {
+		return "", false
+	}
+	return path, true
+}
+
+// FitColWidths fits the widths of a range of columns to their values, within the bounds of the column
+// width settings.  If toCol is negative, the range extends to the last column.
+func (session *Session) FitColWidths(fromCol, toCol int) {
+	if _, cols := session.modelController.Model().Dimensions(); toCol < 0 || toCol >= cols {
+		toCol = cols - 1
+	}
+	for c := fromCol; c <= toCol; c++ {
+		session.modelController.FitColWidth(c, session.MinColWidth, session.MaxColWidth)
+	}
+}
+
+// Input from the frame.  Keys are collected until they match a mapped sequence of keys.  Digits typed
+// before the sequence are the count, which is passed to the command.
 func (session *Session) KeyPressed(key rune, mod int) {
 	// Add the mod key modifier
 	if mod&ui.ModKeyAlt != 0 {
 		key |= ModAlt
 	}
 
-	cmd := session.Commands.KeyMapping(key)
-	if cmd != nil {
-		err := cmd.Do(&CommandContext{session, nil})
-		if err != nil {
-			session.Frame.ShowMessage(err.Error())
+	input := &session.keyInput
+	input.stopTimer()
+
+	// Digits other than zero start a count, while zero only continues one
+	if len(input.keys) == 0 && ((key >= '1' && key <= '9') || (key == '0' && input.count > 0)) {
+		input.count = input.count*10 + int(key-'0')
+		return
+	}
+
+	input.keys = append(input.keys, key)
+	cmd, isPrefix := session.Commands.KeySequenceMapping(input.keys)
+	switch {
+	case isPrefix:
+		session.startKeyTimer()
+	case cmd != nil:
+		session.invokeKeyMapping(cmd)
+	default:
+		// If the keys before this one are mapped, invoke them and start a new sequence with this key
+		var prefixCmd *Command
+		if len(input.keys) > 1 {
+			prefixCmd, _ = session.Commands.KeySequenceMapping(input.keys[:len(input.keys)-1])
+		}
+
+		if prefixCmd != nil {
+			session.invokeKeyMapping(prefixCmd)
+			session.KeyPressed(key, 0)
+		} else {
+			input.reset()
 		}
 	}
 }
 
+// Starts waiting for the next key of a key sequence
+func (session *Session) startKeyTimer() {
+	input := &session.keyInput
+	input.timerID++
+	timerID := input.timerID
+	input.timer = time.AfterFunc(session.KeyTimeout, func() {
+		session.UIManager.Post(func() { session.keySequenceTimedOut(timerID) })
+	})
+}
+
+// Called when the next key of a key sequence was not typed in time.  The keys typed so far are invoked
+// if they are mapped, and discarded if they are not.
+func (session *Session) keySequenceTimedOut(timerID int) {
+	input := &session.keyInput
+	if timerID != input.timerID || len(input.keys) == 0 {
+		return
+	}
+
+	if cmd, _ := session.Commands.KeySequenceMapping(input.keys); cmd != nil {
+		session.invokeKeyMapping(cmd)
+		session.Frame.keysHandled()
+	} else {
+		input.reset()
+	}
+}
+
+// Invokes the command of a key mapping with the count typed before the keys
+func (session *Session) invokeKeyMapping(cmd *Command) {
+	count := session.keyInput.count
+	session.keyInput.reset()
+
+	if err := cmd.Do(&CommandContext{session: session, count: count}); err != nil {
+		session.Frame.ShowMessage(err.Error())
+	}
+}
+
+func (input *keyInput) stopTimer() {
+	if input.timer != nil {
+		input.timer.Stop()
+		input.timer = nil
+	}
+	input.timerID++
+}
+
+func (input *keyInput) reset() {
+	input.stopTimer()
+	input.count = 0
+	input.keys = nil
+}
+
 // The command context used by the session
 type CommandContext struct {
 	session *Session
 	args    []string
+	count   int
 }
 
 func (scc *CommandContext) WithArgs(args []string) *CommandContext {
 	return &CommandContext{
 		session: scc.session,
 		args:    args,
+		count:   scc.count,
 	}
 }
 
@@ -88,6 +309,14 @@ func (scc *CommandContext) Args() []string {
 	return scc.args
 }
 
+// Count returns the count typed before the keys of the command, or 1 if there was none
+func (scc *CommandContext) Count() int {
+	if scc.count <= 0 {
+		return 1
+	}
+	return scc.count
+}
+
 func (scc *CommandContext) ModelVC() *ModelViewCtrl {
 	return scc.session.modelController
 }
@@ -136,11 +365,26 @@ func (sgm *SessionGridModel) CellAttributes(x int, y int) (fg, bg ui.Attribute)
 	colAttrs := sgm.GridViewModel.ColAttrs(y)
 
 	if rowAttrs.Marker != MarkerNone {
-		return markerAttributes[rowAttrs.Marker], 0
+		fg = markerAttributes[rowAttrs.Marker]
 	} else if colAttrs.Marker != MarkerNone {
-		return markerAttributes[colAttrs.Marker], 0
+		fg = markerAttributes[colAttrs.Marker]
+	}
+
+	if headerRows, _ := sgm.GridViewModel.Header(); y < headerRows {
+		fg |= ui.AttrBold
 	}
-	return 0, 0
+	return fg, 0
+}
+
+// Returns the number of header columns and rows
+func (sgm *SessionGridModel) HeaderDimensions() (cols, rows int) {
+	rows, cols = sgm.GridViewModel.Header()
+	return cols, rows
+}
+
+// Returns the label of the column, which is the value of the header row if there is one
+func (sgm *SessionGridModel) ColLabel(col int) string {
+	return sgm.GridViewModel.ColLabel(col)
 }
 
 var markerAttributes = map[Marker]ui.Attribute{
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A setting which can be changed using the set command
+type Setting struct {
+	Name string
+	Doc  string
+
+	// Returns the current value of the setting
+	Get func(session *Session) string
+
+	// Changes the value of the setting
+	Set func(session *Session, value string) error
+}
+
+// A setting mapping
+type SettingMapping struct {
+	Settings map[string]*Setting
+}
+
+// Creates a new, empty setting mapping
+func NewSettingMapping() *SettingMapping {
+	return &SettingMapping{make(map[string]*Setting)}
+}
+
+// Adds a new setting
+func (sm *SettingMapping) Define(name string, doc string, get func(session *Session) string, set func(session *Session, value string) error) {
+	sm.Settings[name] = &Setting{name, doc, get, set}
+}
+
+// Searches for a setting by name.  Returns the setting or nil
+func (sm *SettingMapping) Setting(name string) *Setting {
+	return sm.Settings[name]
+}
+
+// Returns the names of all the settings in sorted order
+func (sm *SettingMapping) Names() []string {
+	names := make([]string, 0, len(sm.Settings))
+	for name := range sm.Settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get returns the value of a setting
+func (sm *SettingMapping) Get(session *Session, name string) (string, error) {
+	setting := sm.Settings[name]
+	if setting == nil {
+		return "", fmt.Errorf("no such setting: %v", name)
+	}
+	return setting.Get(session), nil
+}
+
+// Set changes the value of a setting
+func (sm *SettingMapping) Set(session *Session, name string, value string) error {
+	setting := sm.Settings[name]
+	if setting == nil {
+		return fmt.Errorf("no such setting: %v", name)
+	}
+	if err := setting.Set(session, value); err != nil {
+		return fmt.Errorf("%v: %v", name, err)
+	}
+	return nil
+}
+
+// Registers the settings of the session.
+func (sm *SettingMapping) RegisterSessionSettings() {
+	sm.Define("backup", "Keep a backup of the previous version of a file when saving",
+		func(session *Session) string { return strconv.FormatBool(session.Backup) },
+		func(session *Session, value string) (err error) {
+			session.Backup, err = strconv.ParseBool(value)
+			return err
+		})
+	sm.Define("header", "The number of header rows, and optionally columns, to keep on screen: ROWS[,COLS]",
+		func(session *Session) string {
+			rows, cols := session.modelController.Header()
+			return fmt.Sprintf("%d,%d", rows, cols)
+		},
+		func(session *Session, value string) error {
+			rows, cols, err := parseHeaderSetting(value)
+			if err != nil {
+				return err
+			}
+			session.modelController.SetHeader(rows, cols)
+			return nil
+		})
+	sm.Define("min-col-width", "The minimum width of columns fitted to their values",
+		func(session *Session) string { return strconv.Itoa(session.MinColWidth) },
+		func(session *Session, value string) (err error) {
+			session.MinColWidth, err = parseWidthSetting(value)
+			return err
+		})
+	sm.Define("max-col-width", "The maximum width of columns fitted to their values",
+		func(session *Session) string { return strconv.Itoa(session.MaxColWidth) },
+		func(session *Session, value string) (err error) {
+			session.MaxColWidth, err = parseWidthSetting(value)
+			return err
+		})
+	sm.Define("fit-on-load", "Fit the widths of all columns to their values when a file is loaded",
+		func(session *Session) string { return strconv.FormatBool(session.FitOnLoad) },
+		func(session *Session, value string) (err error) {
+			session.FitOnLoad, err = strconv.ParseBool(value)
+			return err
+		})
+	sm.Define("view-state", "Where to keep the column widths, row markers and cursor position of files: 'none', 'sidecar' or 'user'",
+		func(session *Session) string { return session.ViewState },
+		func(session *Session, value string) error {
+			switch value {
+			case ViewStateNone, ViewStateSidecar, ViewStateUser:
+				session.ViewState = value
+				return nil
+			}
+			return fmt.Errorf("expected 'none', 'sidecar' or 'user' but was '%v'", value)
+		})
+	sm.Define("pipe-codec", "The codec cells are piped through shell commands as: 'csv' or 'tsv'",
+		func(session *Session) string { return session.PipeCodec },
+		func(session *Session, value string) error {
+			if _, isCodec := pipeCodecs[value]; !isCodec {
+				return fmt.Errorf("expected 'csv' or 'tsv' but was '%v'", value)
+			}
+			session.PipeCodec = value
+			return nil
+		})
+	sm.Define("key-timeout", "The time in milliseconds to wait for the next key of a key sequence",
+		func(session *Session) string { return strconv.FormatInt(session.KeyTimeout.Milliseconds(), 10) },
+		func(session *Session, value string) error {
+			millis, err := strconv.Atoi(value)
+			if err != nil || millis < 1 {
+				return fmt.Errorf("expected a time of at least 1 but was '%v'", value)
+			}
+			session.KeyTimeout = time.Duration(millis) * time.Millisecond
+			return nil
+		})
+}
+
+// Registers the settings of the CSV codec.  These change the options of the current source.
+func (sm *SettingMapping) RegisterCsvSettings() {
+	sm.Define("delimiter", "The field delimiter of CSV files",
+		csvSettingGetter(func(opts CsvFileModelSourceOptions) string { return formatRuneSetting(opts.Comma) }),
+		csvSettingSetter(func(opts *CsvFileModelSourceOptions, value string) (err error) {
+			opts.Comma, err = parseRuneSetting(value)
+			if err == nil && opts.Comma == 0 {
+				err = errors.New("delimiter cannot be empty")
+			}
+			return err
+		}))
+	sm.Define("comment", "Lines of CSV files starting with this character are ignored",
+		csvSettingGetter(func(opts CsvFileModelSourceOptions) string { return formatRuneSetting(opts.Comment) }),
+		csvSettingSetter(func(opts *CsvFileModelSourceOptions, value string) (err error) {
+			opts.Comment, err = parseRuneSetting(value)
+			return err
+		}))
+	sm.Define("lazy-quotes", "Allow quotes to appear within unquoted fields of CSV files",
+		csvSettingGetter(func(opts CsvFileModelSourceOptions) string { return strconv.FormatBool(opts.LazyQuotes) }),
+		csvSettingSetter(func(opts *CsvFileModelSourceOptions, value string) (err error) {
+			opts.LazyQuotes, err = strconv.ParseBool(value)
+			return err
+		}))
+	sm.Define("trim-space", "Ignore leading white space of fields in CSV files",
+		csvSettingGetter(func(opts CsvFileModelSourceOptions) string { return strconv.FormatBool(opts.TrimLeadingSpace) }),
+		csvSettingSetter(func(opts *CsvFileModelSourceOptions, value string) (err error) {
+			opts.TrimLeadingSpace, err = strconv.ParseBool(value)
+			return err
+		}))
+	sm.Define("crlf", "Write CSV files with CRLF line endings",
+		csvSettingGetter(func(opts CsvFileModelSourceOptions) string { return strconv.FormatBool(opts.UseCRLF) }),
+		csvSettingSetter(func(opts *CsvFileModelSourceOptions, value string) (err error) {
+			opts.UseCRLF, err = strconv.ParseBool(value)
+			return err
+		}))
+	sm.Define("quote", "When to quote fields of CSV files: 'minimal' or 'all'",
+		csvSettingGetter(func(opts CsvFileModelSourceOptions) string {
+			if opts.QuoteAll {
+				return "all"
+			}
+			return "minimal"
+		}),
+		csvSettingSetter(func(opts *CsvFileModelSourceOptions, value string) error {
+			switch value {
+			case "all":
+				opts.QuoteAll = true
+			case "minimal":
+				opts.QuoteAll = false
+			default:
+				return fmt.Errorf("expected 'minimal' or 'all' but was '%v'", value)
+			}
+			return nil
+		}))
+}
+
+// Registers the settings of the markdown codec.  These change the options of the current source.
+func (sm *SettingMapping) RegisterMarkdownSettings() {
+	sm.Define("md-pad", "Pad the cells of markdown tables so that the columns line up",
+		func(session *Session) string {
+			mdSource, isMdSource := session.Source.(*MarkdownTableModelSource)
+			if !isMdSource {
+				return ""
+			}
+			return strconv.FormatBool(mdSource.Pad)
+		},
+		func(session *Session, value string) (err error) {
+			mdSource, isMdSource := session.Source.(*MarkdownTableModelSource)
+			if !isMdSource {
+				return errors.New("only applies to markdown files")
+			}
+			mdSource.Pad, err = strconv.ParseBool(value)
+			return err
+		})
+}
+
+// Returns a getter of a CSV option of the current source.
+func csvSettingGetter(get func(opts CsvFileModelSourceOptions) string) func(session *Session) string {
+	return func(session *Session) string {
+		csvSource, isCsvSource := session.Source.(*CsvFileModelSource)
+		if !isCsvSource {
+			return ""
+		}
+		return get(csvSource.Options())
+	}
+}
+
+// Returns a setter of a CSV option of the current source.
+func csvSettingSetter(set func(opts *CsvFileModelSourceOptions, value string) error) func(session *Session, value string) error {
+	return func(session *Session, value string) error {
+		csvSource, isCsvSource := session.Source.(*CsvFileModelSource)
+		if !isCsvSource {
+			return errors.New("only applies to CSV files")
+		}
+
+		opts := csvSource.Options()
+		if err := set(&opts, value); err != nil {
+			return err
+		}
+		csvSource.SetOptions(opts)
+		return nil
+	}
+}
+
+// Parses a setting consisting of a single character.  Escape sequences such as '\t' are
+// supported, and an empty value is returned as 0.
+func parseRuneSetting(value string) (rune, error) {
+	switch value {
+	case "":
+		return 0, nil
+	case "tab":
+		return '\t', nil
+	}
+
+	if strings.HasPrefix(value, "\\") {
+		unquoted, err := strconv.Unquote("'" + value + "'")
+		if err != nil {
+			return 0, fmt.Errorf("invalid escape sequence: %v", value)
+		}
+		value = unquoted
+	}
+
+	runes := []rune(value)
+	if len(runes) != 1 {
+		return 0, fmt.Errorf("expected a single character but was '%v'", value)
+	}
+	return runes[0], nil
+}
+
+// Parses the header setting, which is the number of header rows optionally followed by a comma and
+// the number of header columns.
+func parseHeaderSetting(value string) (rows, cols int, err error) {
+	parts := strings.SplitN(value, ",", 2)
+	counts := make([]int, 2)
+	for i, part := range parts {
+		counts[i], err = strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || counts[i] < 0 {
+			return 0, 0, fmt.Errorf("expected ROWS[,COLS] but was '%v'", value)
+		}
+	}
+	return counts[0], counts[1], nil
+}
+
+// Parses a column width, which must be at least 1
+func parseWidthSetting(value string) (int, error) {
+	width, err := strconv.Atoi(value)
+	if err != nil || width < 1 {
+		return 0, fmt.Errorf("expected a width of at least 1 but was '%v'", value)
+	}
+	return width, nil
+}
+
+func formatRuneSetting(r rune) string {
+	if r == 0 {
+		return ""
+	}
+	quoted := strconv.QuoteRune(r)
+	return quoted[1 : len(quoted)-1]
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSettingMapping_CsvSettings(t *testing.T) {
+	newSession := func() *Session {
+		session := &Session{
+			Source:   NewCsvFileModelSource("data.csv", CsvFileModelSourceOptions{Comma: ','}),
+			Settings: NewSettingMapping(),
+		}
+		session.Settings.RegisterCsvSettings()
+		return session
+	}
+
+	t.Run("should change the options of the source", func(t *testing.T) {
+		session := newSession()
+
+		assert.NoError(t, session.Settings.Set(session, "delimiter", `\t`))
+		assert.NoError(t, session.Settings.Set(session, "comment", "#"))
+		assert.NoError(t, session.Settings.Set(session, "crlf", "true"))
+		assert.NoError(t, session.Settings.Set(session, "quote", "all"))
+
+		assert.Equal(t, CsvFileModelSourceOptions{
+			Comma:    '\t',
+			Comment:  '#',
+			UseCRLF:  true,
+			QuoteAll: true,
+		}, session.Source.(*CsvFileModelSource).Options())
+
+		value, err := session.Settings.Get(session, "delimiter")
+		assert.NoError(t, err)
+		assert.Equal(t, `\t`, value)
+	})
+
+	t.Run("should return error on invalid values", func(t *testing.T) {
+		scenarios := []struct{ name, value string }{
+			{"delimiter", ""},
+			{"delimiter", "ab"},
+			{"lazy-quotes", "maybe"},
+			{"quote", "some"},
+			{"no-such-setting", "x"},
+		}
+		for _, scenario := range scenarios {
+			t.Run(scenario.name+"="+scenario.value, func(t *testing.T) {
+				session := newSession()
+				assert.Error(t, session.Settings.Set(session, scenario.name, scenario.value))
+			})
+		}
+	})
+
+	t.Run("should return error if source is not a CSV file", func(t *testing.T) {
+		session := newSession()
+		session.Source = &JiraTableModelSource{Filename: "table.jira"}
+
+		assert.Error(t, session.Settings.Set(session, "delimiter", ";"))
+	})
+}
+
+func TestSettingMapping_MarkdownSettings(t *testing.T) {
+	newSession := func(source ModelSource) *Session {
+		session := &Session{Source: source, Settings: NewSettingMapping()}
+		session.Settings.RegisterMarkdownSettings()
+		return session
+	}
+
+	t.Run("should change the padding of the source", func(t *testing.T) {
+		source := &MarkdownTableModelSource{Filename: "table.md"}
+		session := newSession(source)
+
+		assert.NoError(t, session.Settings.Set(session, "md-pad", "true"))
+		assert.True(t, source.Pad)
+
+		value, err := session.Settings.Get(session, "md-pad")
+		assert.NoError(t, err)
+		assert.Equal(t, "true", value)
+
+		assert.NoError(t, session.Settings.Set(session, "md-pad", "false"))
+		assert.False(t, source.Pad)
+	})
+
+	t.Run("should return error if source is not a markdown file", func(t *testing.T) {
+		session := newSession(&JiraTableModelSource{Filename: "table.jira"})
+
+		assert.Error(t, session.Settings.Set(session, "md-pad", "true"))
+	})
+}
+
+func TestSettingMapping_HeaderSetting(t *testing.T) {
+	newSession := func() *Session {
+		session := &Session{
+			Settings:        NewSettingMapping(),
+			modelController: NewGridViewModel(NewSingleCellStdModel()),
+		}
+		session.Settings.RegisterSessionSettings()
+		return session
+	}
+
+	t.Run("should set the header rows and columns", func(t *testing.T) {
+		scenarios := []struct {
+			value      string
+			rows, cols int
+		}{
+			{"1", 1, 0},
+			{"2,1", 2, 1},
+			{" 1 , 3 ", 1, 3},
+			{"0", 0, 0},
+		}
+		for _, scenario := range scenarios {
+			t.Run(scenario.value, func(t *testing.T) {
+				session := newSession()
+
+				assert.NoError(t, session.Settings.Set(session, "header", scenario.value))
+				rows, cols := session.modelController.Header()
+				assert.Equal(t, scenario.rows, rows)
+				assert.Equal(t, scenario.cols, cols)
+			})
+		}
+	})
+
+	t.Run("should return error on invalid values", func(t *testing.T) {
+		for _, value := range []string{"", "x", "-1", "1,x", "1,-2"} {
+			session := newSession()
+			assert.Error(t, session.Settings.Set(session, "header", value), value)
+		}
+	})
+}
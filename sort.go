@@ -0,0 +1,238 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A column to sort rows by
+type SortKey struct {
+	Col        int
+	Descending bool
+}
+
+// Parses a comma-separated list of sort keys.  Each key is a column, optionally followed by ":asc" or
+// ":desc" to set the direction of that column.  Keys without a direction use the default.  Columns are
+// resolved using columnIndex.
+func parseSortKeys(spec string, defaultDescending bool, columnIndex func(ref string) (int, error)) ([]SortKey, error) {
+	keys := make([]SortKey, 0)
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		key := SortKey{Descending: defaultDescending}
+		if sep := strings.LastIndex(field, ":"); sep >= 0 {
+			switch strings.ToLower(field[sep+1:]) {
+			case "asc":
+				key.Descending = false
+				field = field[:sep]
+			case "desc":
+				key.Descending = true
+				field = field[:sep]
+			}
+		}
+
+		col, err := columnIndex(field)
+		if err != nil {
+			return nil, err
+		}
+		key.Col = col
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no columns to sort by")
+	}
+	return keys, nil
+}
+
+// Returns the order of the rows sorted by the keys.  The returned slice holds the current index of
+// each row in sorted order.  The sort is stable, so rows which compare equal keep their order.
+func sortedRowOrder(model Model, fromRow int, keys []SortKey) []int {
+	rows, _ := model.Dimensions()
+	order := make([]int, 0, rows-fromRow)
+	for r := fromRow; r < rows; r++ {
+		order = append(order, r)
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		for _, key := range keys {
+			a := model.CellValue(order[i], key.Col)
+			b := model.CellValue(order[j], key.Col)
+
+			// Blank values are always placed last
+			if (a == "") != (b == "") {
+				return b == ""
+			}
+
+			cmp := compareValues(a, b)
+			if key.Descending {
+				cmp = -cmp
+			}
+			if cmp != 0 {
+				return cmp < 0
+			}
+		}
+		return false
+	})
+	return order
+}
+
+// Compares two cell values.  Numbers and dates are compared by value, with numbers ordered before
+// dates, and dates ordered before other text.  Other text is compared naturally, so that runs of
+// digits within the text are compared by their numeric value.
+func compareValues(a, b string) int {
+	aNum, aIsNum := parseSortNumber(a)
+	bNum, bIsNum := parseSortNumber(b)
+	if aIsNum && bIsNum {
+		return compareFloats(aNum, bNum)
+	} else if aIsNum != bIsNum {
+		return compareBools(bIsNum, aIsNum)
+	}
+
+	aTime, aIsTime := parseSortDate(a)
+	bTime, bIsTime := parseSortDate(b)
+	if aIsTime && bIsTime {
+		return compareFloats(float64(aTime.UnixNano()), float64(bTime.UnixNano()))
+	} else if aIsTime != bIsTime {
+		return compareBools(bIsTime, aIsTime)
+	}
+
+	return naturalCompare(a, b)
+}
+
+// The pattern of numbers recognised when sorting.  Unlike strconv.ParseFloat, this excludes hex numbers,
+// infinities and NaN, which are sorted as text.
+var sortNumberPattern = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$`)
+
+func parseSortNumber(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	if !sortNumberPattern.MatchString(s) {
+		return 0, false
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	return n, err == nil
+}
+
+// The layouts of dates recognised when sorting
+var sortDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02",
+	"2 Jan 2006",
+	"2 January 2006",
+	"Jan 2, 2006",
+	"January 2, 2006",
+	time.RFC1123,
+	time.RFC1123Z,
+}
+
+func parseSortDate(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range sortDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Compares two strings naturally.  Runs of digits are compared by numeric value, and other text is
+// compared ignoring case.  If the strings are otherwise equal, they are compared exactly.
+func naturalCompare(a, b string) int {
+	as, bs := a, b
+	for as != "" && bs != "" {
+		ar, _ := utf8.DecodeRuneInString(as)
+		br, _ := utf8.DecodeRuneInString(bs)
+
+		if isDigit(ar) && isDigit(br) {
+			var aDigits, bDigits string
+			aDigits, as = splitDigits(as)
+			bDigits, bs = splitDigits(bs)
+			if cmp := compareDigits(aDigits, bDigits); cmp != 0 {
+				return cmp
+			}
+			continue
+		}
+
+		if cmp := compareRunes(unicode.ToLower(ar), unicode.ToLower(br)); cmp != 0 {
+			return cmp
+		}
+		as = as[utf8.RuneLen(ar):]
+		bs = bs[utf8.RuneLen(br):]
+	}
+
+	if cmp := compareBools(as != "", bs != ""); cmp != 0 {
+		return cmp
+	}
+	return strings.Compare(a, b)
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+func splitDigits(s string) (digits string, rest string) {
+	i := 0
+	for i < len(s) && isDigit(rune(s[i])) {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+// Compares two runs of digits by numeric value, without any limit on their length
+func compareDigits(a, b string) int {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		return compareInts(len(a), len(b))
+	}
+	return strings.Compare(a, b)
+}
+
+func compareRunes(a, b rune) int {
+	return compareInts(int(a), int(b))
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// Compares two bools, with false ordered before true
+func compareBools(a, b bool) int {
+	switch {
+	case !a && b:
+		return -1
+	case a && !b:
+		return 1
+	}
+	return 0
+}
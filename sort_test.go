@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSortKeys(t *testing.T) {
+	mvc := NewGridViewModel(NewStdModelFromSlice([][]string{
+		{"name", "qty", "team:id", "when"},
+	}))
+	mvc.SetHeader(1, 0)
+
+	t.Run("should parse columns with directions", func(t *testing.T) {
+		keys, err := parseSortKeys("2, 0:desc,1:asc", false, mvc.ColumnIndex)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []SortKey{{Col: 2}, {Col: 0, Descending: true}, {Col: 1}}, keys)
+	})
+
+	t.Run("should use the default direction", func(t *testing.T) {
+		keys, err := parseSortKeys("1,3:asc", true, mvc.ColumnIndex)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []SortKey{{Col: 1, Descending: true}, {Col: 3}}, keys)
+	})
+
+	t.Run("should refer to columns by name", func(t *testing.T) {
+		keys, err := parseSortKeys("Team:id:desc,when,NAME", false, mvc.ColumnIndex)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []SortKey{{Col: 2, Descending: true}, {Col: 3}, {Col: 0}}, keys)
+	})
+
+	t.Run("should reject invalid keys", func(t *testing.T) {
+		for _, spec := range []string{"", "x", "-1", "4", "1:up", ","} {
+			_, err := parseSortKeys(spec, false, mvc.ColumnIndex)
+			assert.Error(t, err, spec)
+		}
+	})
+}
+
+func TestCompareValues(t *testing.T) {
+	scenarios := []struct {
+		a, b     string
+		expected int
+	}{
+		{"2", "10", -1},
+		{"-3.5", "-3", -1},
+		{"1e3", "999", 1},
+		{"2021-03-04", "2021-10-01", -1},
+		{"4 Mar 2021", "1 Oct 2021", -1},
+		{"file2.txt", "file10.txt", -1},
+		{"file002", "file2", -1},
+		{"apple", "Banana", -1},
+		{"Apple", "apple", -1},
+		{"abc", "abcd", -1},
+		{"10", "2021-01-01", -1},
+		{"2021-01-01", "alpha", -1},
+		{"same", "same", 0},
+		{"NaN", "1", 1},
+		{"Inf", "-1e300", 1},
+		{"0x10", "2", 1},
+		{"1e999", "1", 1},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.a+" vs "+scenario.b, func(t *testing.T) {
+			assert.Equal(t, scenario.expected, compareValues(scenario.a, scenario.b))
+			assert.Equal(t, -scenario.expected, compareValues(scenario.b, scenario.a))
+		})
+	}
+}
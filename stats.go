@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// The number of most frequent values shown by stats
+	topStatsValues = 10
+
+	// The number of values above which stats are computed in the background, so that the UI stays responsive
+	backgroundStatsValues = 50000
+)
+
+// Statistics of a set of values, such as those of a column
+type valueStats struct {
+	Count    int
+	Blanks   int
+	Distinct int
+
+	// The statistics of the values which are numbers.  These are only set if Numbers is not 0.
+	Numbers                     int
+	Min, Max, Sum, Mean, Median float64
+
+	// The most frequent values which are not blank, in order of frequency
+	TopValues []valueCount
+}
+
+// A value and the number of times it appears
+type valueCount struct {
+	Value string
+	Count int
+}
+
+// Computes the statistics of values, including up to topN of the most frequent values.  Values which
+// compare equal as numbers, such as "1" and "1.0", are still distinct values.
+func computeValueStats(values []string, topN int) *valueStats {
+	stats := &valueStats{Count: len(values)}
+	counts := make(map[string]int)
+	nums := make([]float64, 0)
+
+	for _, value := range values {
+		if strings.TrimSpace(value) == "" {
+			stats.Blanks++
+			continue
+		}
+
+		counts[value]++
+		if n, isNum := parseSortNumber(value); isNum {
+			nums = append(nums, n)
+		}
+	}
+	stats.Distinct = len(counts)
+
+	if stats.Numbers = len(nums); stats.Numbers > 0 {
+		sort.Float64s(nums)
+		stats.Min, stats.Max = nums[0], nums[len(nums)-1]
+		for _, n := range nums {
+			stats.Sum += n
+		}
+		stats.Mean = stats.Sum / float64(len(nums))
+		if mid := len(nums) / 2; len(nums)%2 == 1 {
+			stats.Median = nums[mid]
+		} else {
+			stats.Median = (nums[mid-1] + nums[mid]) / 2
+		}
+	}
+
+	stats.TopValues = make([]valueCount, 0, len(counts))
+	for value, count := range counts {
+		stats.TopValues = append(stats.TopValues, valueCount{value, count})
+	}
+	sort.Slice(stats.TopValues, func(i, j int) bool {
+		a, b := stats.TopValues[i], stats.TopValues[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return compareValues(a.Value, b.Value) < 0
+	})
+	if len(stats.TopValues) > topN {
+		stats.TopValues = stats.TopValues[:topN]
+	}
+	return stats
+}
+
+// Returns the lines of the statistics, as shown by the stats command
+func (stats *valueStats) lines() []string {
+	lines := []string{
+		fmt.Sprintf("Count     %d", stats.Count),
+		fmt.Sprintf("Blanks    %d", stats.Blanks),
+		fmt.Sprintf("Distinct  %d", stats.Distinct),
+	}
+
+	if stats.Numbers > 0 {
+		lines = append(lines,
+			fmt.Sprintf("Numbers   %d", stats.Numbers),
+			"Min       "+formatExprNumber(stats.Min),
+			"Max       "+formatExprNumber(stats.Max),
+			"Sum       "+formatExprNumber(stats.Sum),
+			"Mean      "+formatExprNumber(stats.Mean),
+			"Median    "+formatExprNumber(stats.Median),
+		)
+	}
+
+	if len(stats.TopValues) > 0 {
+		countWidth := len(strconv.Itoa(stats.TopValues[0].Count))
+		lines = append(lines, "", "Most frequent:")
+		for _, vc := range stats.TopValues {
+			lines = append(lines, fmt.Sprintf("  %*d  %v", countWidth, vc.Count, vc.Value))
+		}
+	}
+	return lines
+}
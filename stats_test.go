@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComputeValueStats(t *testing.T) {
+	t.Run("should compute the stats of numbers", func(t *testing.T) {
+		stats := computeValueStats([]string{"3", "1.5", "", "10", "3", " ", "n/a"}, 2)
+
+		assert.Equal(t, &valueStats{
+			Count:     7,
+			Blanks:    2,
+			Distinct:  4,
+			Numbers:   4,
+			Min:       1.5,
+			Max:       10,
+			Sum:       17.5,
+			Mean:      4.375,
+			Median:    3,
+			TopValues: []valueCount{{"3", 2}, {"1.5", 1}},
+		}, stats)
+	})
+
+	t.Run("should compute the median of an even number of numbers", func(t *testing.T) {
+		stats := computeValueStats([]string{"4", "1", "2", "8"}, 10)
+
+		assert.Equal(t, 3.0, stats.Median)
+	})
+
+	t.Run("should only count values which are not numbers", func(t *testing.T) {
+		stats := computeValueStats([]string{"b", "a", "b", "c", "a", "b"}, 10)
+
+		assert.Equal(t, 0, stats.Numbers)
+		assert.Equal(t, 3, stats.Distinct)
+		assert.Equal(t, []valueCount{{"b", 3}, {"a", 2}, {"c", 1}}, stats.TopValues)
+		assert.Equal(t, []string{
+			"Count     6",
+			"Blanks    0",
+			"Distinct  3",
+			"",
+			"Most frequent:",
+			"  3  b",
+			"  2  a",
+			"  1  c",
+		}, stats.lines())
+	})
+
+	t.Run("should compute the stats of no values", func(t *testing.T) {
+		stats := computeValueStats(nil, 10)
+
+		assert.Equal(t, []string{"Count     0", "Blanks    0", "Distinct  0"}, stats.lines())
+	})
+}
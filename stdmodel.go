@@ -108,3 +108,7 @@ func (sm *StdModel) strSliceToCell(row []string, targetRowLen int) []Cell {
 func (sm *StdModel) IsDirty() bool {
 	return sm.dirty
 }
+
+func (sm *StdModel) ClearDirty() {
+	sm.dirty = false
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// The text of a file around a table.  This is kept when the table is read, so that it can be written
+// back around the table.
+type tableText struct {
+	before, after []string
+}
+
+// Keeps the lines of the file outside of the table, which spans the lines from start up to end.  A
+// negative start means that the file has no table, in which case all the lines are kept before the
+// table which will be written.
+func (t *tableText) keepLines(lines []string, start, end int) {
+	if start >= 0 {
+		t.before, t.after = lines[:start], lines[end:]
+		return
+	}
+
+	t.before, t.after = lines, nil
+	if len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) != "" {
+		t.before = append(t.before, "")
+	}
+}
+
+// A row of a table as it originally appeared in the file
+type rawTableRow struct {
+	// The text of the row, which may span multiple lines
+	text string
+
+	// The values of the row, before being padded to the number of columns of the table
+	values []string
+}
+
+// Searches for an unused row with the given values, once padded, which is also accepted by the
+// function.  The row originally at the same position is preferred.
+func findRawTableRow(rows []rawTableRow, row int, values []string, used map[int]bool, accept func(idx int) bool) (int, bool) {
+	matches := func(idx int) bool {
+		raw := rows[idx]
+		if used[idx] || len(raw.values) > len(values) || !accept(idx) {
+			return false
+		}
+		for c, value := range values {
+			if (c < len(raw.values) && raw.values[c] != value) || (c >= len(raw.values) && value != "") {
+				return false
+			}
+		}
+		return true
+	}
+
+	if row < len(rows) && matches(row) {
+		return row, true
+	}
+	for idx := range rows {
+		if matches(idx) {
+			return idx, true
+		}
+	}
+	return 0, false
+}
+
+// Reads the lines of a text file, without the line endings
+func readTextLines(filename string) ([]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	lines := make([]string, 0)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimRight(scanner.Text(), "\r"))
+	}
+	return lines, scanner.Err()
+}
+
+// Writes lines of text, each followed by a newline
+func writeTextLines(out io.Writer, lines []string) error {
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(out, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
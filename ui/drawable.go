@@ -48,17 +48,21 @@ func (dc *DrawContext) HorizRule(y int, ch rune) {
 	}
 }
 
-// Prints a string at a specific offset.  This will be bounded by the size of the drawing context.
+// Prints a string at a specific offset.  This will be bounded by the size of the drawing context.  Wide
+// characters which do not completely fit within the context will not be drawn.
 func (dc *DrawContext) Print(x, y int, str string) {
-	for _, ch := range str {
-		dc.DrawRune(x, y, ch)
-		x++
+	for _, g := range glyphs(str) {
+		if x+g.width > dc.W {
+			return
+		}
+		dc.DrawRune(x, y, g.ch)
+		x += g.width
 	}
 }
 
 // Prints a right-justified string at a specific offset.  This will be bounded by the size of the drawing context.
 func (dc *DrawContext) PrintRight(x, y int, str string) {
-	l := len(str)
+	l := TextWidth(str)
 	dc.Print(x-l, y, str)
 }
 
@@ -2,6 +2,8 @@
 
 package ui
 
+import "unicode"
+
 // The set of attributes a specific cell can have
 type Attribute uint16
 
@@ -25,9 +27,10 @@ const (
 	AttrReverse
 )
 
-// Special keys
+// Special keys.  These are beyond the range of valid Unicode code points, so they cannot be confused
+// with a typed character.
 const (
-	KeyCtrlSpace rune = 0x8000 + iota
+	KeyCtrlSpace rune = unicode.MaxRune + 1 + iota
 	KeyCtrlA
 	KeyCtrlB
 	KeyCtrlC
@@ -102,6 +105,12 @@ const (
 	// Event indicating a key press.  The key is set in Ch and modifications
 	// are set in Or
 	EventKeyPress
+
+	// Event indicating that there are no more events, and the UI loop should return
+	EventQuit
+
+	// Event indicating that the wait for an event was interrupted
+	EventInterrupt
 )
 
 const (
@@ -140,4 +149,8 @@ type Driver interface {
 
 	// Hide the cursor
 	HideCursor()
+
+	// Interrupts a wait for an event, which will return an EventInterrupt event.  This can be called
+	// from any goroutine.
+	Interrupt()
 }
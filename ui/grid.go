@@ -32,6 +32,18 @@ type GridModel interface {
 	CellAttributes(int, int) (fg, bg Attribute)
 }
 
+// A grid model with header rows and columns.  These are frozen, so that they remain on screen while the
+// rest of the grid is scrolled.
+type HeaderGridModel interface {
+	GridModel
+
+	// Returns the number of header columns and rows
+	HeaderDimensions() (cols, rows int)
+
+	// Returns the label of the column displayed in the top ruler.  If empty, the column index is used.
+	ColLabel(col int) string
+}
+
 type gridPoint int
 
 /**
@@ -46,6 +58,9 @@ type Grid struct {
 	selCellY  int
 	cellsWide int // Measured number of cells.  Recalculated on redraw.
 	cellsHigh int
+
+	hasSelection bool     // True if a range of cells is selected
+	selection    gridRect // The selected range of cells.  Bounds are inclusive.
 }
 
 /**
@@ -69,7 +84,7 @@ func newGridRect(x1, y1, x2, y2 int) gridRect {
  * Creates a new grid.
  */
 func NewGrid(model GridModel) *Grid {
-	return &Grid{model, 0, 0, 0, 0, -1, -1}
+	return &Grid{model: model, cellsWide: -1, cellsHigh: -1}
 }
 
 // Returns the model
@@ -99,18 +114,22 @@ func (grid *Grid) CurrentCellDisplayValue() string {
 	}
 }
 
-// Moves the currently selected cell by a delta.  This will be implemented as single stepped
-// moveTo calls to handle invalid cells.
+// Moves the currently selected cell by a delta.  The delta is the number of visible cells to move
+// by, so hidden rows and columns are skipped.
 func (grid *Grid) MoveBy(x int, y int) {
-	grid.MoveTo(grid.selCellX+x, grid.selCellY+y)
+	maxX, maxY := grid.model.Dimensions()
+	newX := stepVisible(grid.selCellX, x, maxX, grid.model.ColWidth)
+	newY := stepVisible(grid.selCellY, y, maxY, grid.model.RowHeight)
+	grid.MoveTo(newX, newY)
 }
 
 // Moves the currently selected cell to a specific row.  The row must be valid, otherwise the
-// currently selected cell will not be changed.  Returns true if the move was successful
+// currently selected cell will not be changed.  If the cell is within a hidden row or column,
+// the nearest visible cell is selected instead.
 func (grid *Grid) MoveTo(newX, newY int) {
 	maxX, maxY := grid.model.Dimensions()
-	newX = intMinMax(newX, 0, maxX-1)
-	newY = intMinMax(newY, 0, maxY-1)
+	newX = nearestVisible(intMinMax(newX, 0, maxX-1), maxX, grid.model.ColWidth)
+	newY = nearestVisible(intMinMax(newY, 0, maxY-1), maxY, grid.model.RowHeight)
 
 	if grid.isCellValid(newX, newY) {
 		grid.selCellX = newX
@@ -124,38 +143,117 @@ func (grid *Grid) CellPosition() (int, int) {
 	return grid.selCellX, grid.selCellY
 }
 
+// Sets the selected range of cells.  The range is inclusive of both corners, which can be
+// specified in any order.
+func (grid *Grid) SetSelection(x1, y1, x2, y2 int) {
+	grid.selection = newGridRect(intMin(x1, x2), intMin(y1, y2), intMax(x1, x2), intMax(y1, y2))
+	grid.hasSelection = true
+}
+
+// Clears the selected range of cells.
+func (grid *Grid) ClearSelection() {
+	grid.hasSelection = false
+}
+
+// Returns the selected range of cells, with the top-left corner first.  The final value
+// will be false if there is no selection.
+func (grid *Grid) Selection() (x1, y1, x2, y2 int, hasSelection bool) {
+	sel := grid.selection
+	return int(sel.x1), int(sel.y1), int(sel.x2), int(sel.y2), grid.hasSelection
+}
+
+// Returns true if the cell is within the selected range
+func (grid *Grid) isCellSelected(x, y int) bool {
+	if !grid.hasSelection {
+		return false
+	}
+	sel := grid.selection
+	return (gridPoint(x) >= sel.x1) && (gridPoint(x) <= sel.x2) && (gridPoint(y) >= sel.y1) && (gridPoint(y) <= sel.y2)
+}
+
 // Returns true if the user can enter the specific cell
 func (grid *Grid) isCellValid(x int, y int) bool {
 	maxX, maxY := grid.model.Dimensions()
 	return (x >= 0) && (y >= 0) && (x < maxX) && (y < maxY)
 }
 
+// Returns the number of frozen columns and rows, which will not exceed the dimensions of the model
+func (grid *Grid) frozenDimensions() (int, int) {
+	headerModel, hasHeaders := grid.model.(HeaderGridModel)
+	if !hasHeaders {
+		return 0, 0
+	}
+
+	maxX, maxY := grid.model.Dimensions()
+	cols, rows := headerModel.HeaderDimensions()
+	return intMinMax(cols, 0, maxX), intMinMax(rows, 0, maxY)
+}
+
 // Determine the topmost cell based on the location of the currently selected cell
 func (grid *Grid) reposition() {
+	frozenX, frozenY := grid.frozenDimensions()
+	grid.viewCellX = intMax(grid.viewCellX, frozenX)
+	grid.viewCellY = intMax(grid.viewCellY, frozenY)
 
-	// If we have no measurement information, forget it.
+	// If we have no measurement information, scroll to the selected cell.
 	if (grid.cellsWide == -1) || (grid.cellsHigh == -1) {
+		grid.viewCellX = intMax(grid.selCellX, frozenX)
+		grid.viewCellY = intMax(grid.selCellY, frozenY)
 		return
 	}
 
-	if grid.selCellX < grid.viewCellX {
-		grid.viewCellX = grid.selCellX
-	} else if grid.selCellX >= (grid.viewCellX + grid.cellsWide - 3) {
-		grid.viewCellX = grid.selCellX - (grid.cellsWide - 3)
+	grid.viewCellX = repositionView(grid.viewCellX, grid.selCellX, frozenX, grid.cellsWide)
+	grid.viewCellY = repositionView(grid.viewCellY, grid.selCellY, frozenY, grid.cellsHigh)
+}
+
+// Returns the first scrolled row or column which keeps the selected one in view.  Frozen rows and
+// columns are always in view, and take up some of the measured cells.  Selecting a frozen row or
+// column scrolls back to the start.
+func repositionView(view, sel, frozen, measured int) int {
+	if sel < frozen {
+		return frozen
+	}
+
+	scrolled := measured - frozen
+	if sel < view {
+		view = sel
+	} else if sel >= (view + scrolled - 3) {
+		view = sel - (scrolled - 3)
 	}
+	return intMin(view, sel)
+}
 
-	if grid.selCellY < grid.viewCellY {
-		grid.viewCellY = grid.selCellY
-	} else if grid.selCellY >= (grid.viewCellY + grid.cellsHigh - 3) {
-		grid.viewCellY = grid.selCellY - (grid.cellsHigh - 3)
+// Returns the model index of the row or column displayed at a cell index.  Cell index 0 is the ruler,
+// which is followed by the frozen rows or columns, and then the scrolled ones starting from view.
+func viewCellToModel(cell, view, frozen int) int {
+	if cell-1 < frozen {
+		return cell - 1
 	}
+	return cell - 1 - frozen + intMax(view, frozen)
+}
+
+// Returns the model cell displayed at a cell index
+func (grid *Grid) cellToModel(cellX, cellY int) (int, int) {
+	frozenX, frozenY := grid.frozenDimensions()
+	return viewCellToModel(cellX, grid.viewCellX, frozenX), viewCellToModel(cellY, grid.viewCellY, frozenY)
+}
+
+// Returns the label of a column displayed in the top ruler
+func (grid *Grid) colLabel(col int) string {
+	if headerModel, hasHeaders := grid.model.(HeaderGridModel); hasHeaders {
+		if maxX, _ := grid.model.Dimensions(); col < maxX {
+			if label := headerModel.ColLabel(col); label != "" {
+				return label
+			}
+		}
+	}
+	return strconv.Itoa(col)
 }
 
 // Gets the cell value and attributes of a particular cell
 func (grid *Grid) getCellData(cellX, cellY int) (text string, fg, bg Attribute) {
 	// The fixed cells
-	modelCellX := cellX - 1 + grid.viewCellX
-	modelCellY := cellY - 1 + grid.viewCellY
+	modelCellX, modelCellY := grid.cellToModel(cellX, cellY)
 	modelMaxX, modelMaxY := grid.model.Dimensions()
 
 	if (cellX == 0) && (cellY == 0) {
@@ -168,9 +266,9 @@ func (grid *Grid) getCellData(cellX, cellY int) (text string, fg, bg Attribute)
 		}
 	} else if cellY == 0 {
 		if modelCellX == grid.selCellX {
-			return strconv.Itoa(modelCellX), AttrBold | AttrReverse, AttrReverse
+			return grid.colLabel(modelCellX), AttrBold | AttrReverse, AttrReverse
 		} else {
-			return strconv.Itoa(modelCellX), AttrBold, 0
+			return grid.colLabel(modelCellX), AttrBold, 0
 		}
 	} else {
 		// The data from the model
@@ -180,6 +278,8 @@ func (grid *Grid) getCellData(cellX, cellY int) (text string, fg, bg Attribute)
 
 			if (modelCellX == grid.selCellX) && (modelCellY == grid.selCellY) {
 				return value, fg | AttrReverse, bg | AttrReverse
+			} else if grid.isCellSelected(modelCellX, modelCellY) {
+				return value, fg | AttrBold, ColorBlue
 			} else {
 				return value, fg, bg
 			}
@@ -194,8 +294,7 @@ func (grid *Grid) getCellDimensions(cellX, cellY int) (width, height int) {
 
 	var cellWidth, cellHeight int
 
-	modelCellX := cellX - 1 + grid.viewCellX
-	modelCellY := cellY - 1 + grid.viewCellY
+	modelCellX, modelCellY := grid.cellToModel(cellX, cellY)
 	modelMaxX, modelMaxY := grid.model.Dimensions()
 
 	// Get the cell width & height from model (if within range)
@@ -231,13 +330,17 @@ func (grid *Grid) getCellDimensions(cellX, cellY int) (width, height int) {
  * of the cell.  The sx and sy determine the screen position of the cell top-left.
  */
 func (grid *Grid) renderCell(ctx *DrawContext, cellClipRect gridRect, sx int, sy int, text string, fg, bg Attribute) {
+	// The last column of the cell is left blank to separate it from the next cell
+	textCells := layoutText(text, intMax(int(cellClipRect.x2)-1, 0), true)
+
 	for x := cellClipRect.x1; x <= cellClipRect.x2; x++ {
 		for y := cellClipRect.y1; y <= cellClipRect.y2; y++ {
 			currRune := ' '
-			if y == 0 {
-				textPos := int(x)
-				if textPos < len(text) {
-					currRune = rune(text[textPos])
+			if y == 0 && int(x) < len(textCells) {
+				currRune = textCells[x]
+				if currRune == 0 {
+					// Covered by the wide rune to the left
+					continue
 				}
 			}
 
@@ -262,7 +365,7 @@ func (grid *Grid) renderColumn(ctx *DrawContext, screenViewPort gridRect, cellX
 	colWidth, _ := grid.getCellDimensions(cellX, cellY)
 	colWidth -= cellOffsetX
 	if colWidth > screenWidth {
-		colWidth = screenHeight
+		colWidth = screenWidth
 	}
 
 	// The maximum
@@ -273,6 +376,12 @@ func (grid *Grid) renderColumn(ctx *DrawContext, screenViewPort gridRect, cellX
 
 		// Cap the row height if it will go beyond the edge of the viewport.
 		_, rowHeight := grid.getCellDimensions(cellX, cellY)
+		if rowHeight == 0 {
+			// The row is hidden
+			cellY++
+			cellsHigh++
+			continue
+		}
 		if screenY+rowHeight > maxScreenY {
 			rowHeight = maxScreenY - screenY
 		}
@@ -299,6 +408,13 @@ func (grid *Grid) renderGrid(ctx *DrawContext, screenViewPort gridRect, cellX in
 	var cellsWide = 0
 
 	for screenViewPort.x1 < screenViewPort.x2 {
+		if colWidth, _ := grid.getCellDimensions(cellX, cellY); colWidth == 0 {
+			// The column is hidden
+			cellX++
+			cellsWide++
+			continue
+		}
+
 		screenViewPort.x1, cellsHigh = grid.renderColumn(ctx, screenViewPort, cellX, cellY, cellOffsetX, cellOffsetY)
 		cellX = cellX + 1
 		cellsWide++
@@ -369,6 +485,43 @@ func (grid *Grid) KeyPressed(key rune, mod int) {
 	}
 }
 
+// Returns the index reached by moving a number of visible rows or columns from an index.  Slices with a
+// size of 0 are hidden and are skipped.  The move stops at the last visible slice in that direction.
+func stepVisible(from, delta, count int, sizeOf func(int) int) int {
+	dir := 1
+	if delta < 0 {
+		dir, delta = -1, -delta
+	}
+
+	idx := from
+	for ; delta > 0; delta-- {
+		next := idx + dir
+		for next >= 0 && next < count && sizeOf(next) == 0 {
+			next += dir
+		}
+		if next < 0 || next >= count {
+			break
+		}
+		idx = next
+	}
+	return idx
+}
+
+// Returns the index of the visible row or column nearest to an index, preferring those after it.  If
+// all are hidden, the index is returned unchanged.
+func nearestVisible(idx, count int, sizeOf func(int) int) int {
+	if idx < 0 || idx >= count || sizeOf(idx) != 0 {
+		return idx
+	}
+	if next := stepVisible(idx, 1, count, sizeOf); next != idx {
+		return next
+	}
+	if prev := stepVisible(idx, -1, count, sizeOf); prev != idx {
+		return prev
+	}
+	return idx
+}
+
 // --------------------------------------------------------------------------------------------
 // Test ModelVC
 
@@ -0,0 +1,174 @@
+package ui
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrid_MoveBy(t *testing.T) {
+	newGrid := func() *Grid {
+		return NewGrid(&testGridModel{
+			values: [][]string{
+				{"a0", "b0", "c0"},
+				{"a1", "b1", "c1"},
+				{"a2", "b2", "c2"},
+				{"a3", "b3", "c3"},
+				{"a4", "b4", "c4"},
+			},
+			hiddenRows: map[int]bool{1: true, 2: true},
+			hiddenCols: map[int]bool{1: true},
+		})
+	}
+
+	t.Run("should skip hidden rows and columns", func(t *testing.T) {
+		grid := newGrid()
+
+		grid.MoveBy(1, 1)
+		x, y := grid.CellPosition()
+		assert.Equal(t, 2, x)
+		assert.Equal(t, 3, y)
+
+		grid.MoveBy(-1, -1)
+		x, y = grid.CellPosition()
+		assert.Equal(t, 0, x)
+		assert.Equal(t, 0, y)
+	})
+
+	t.Run("should stop at the last visible row", func(t *testing.T) {
+		grid := newGrid()
+
+		grid.MoveBy(0, 25)
+		_, y := grid.CellPosition()
+		assert.Equal(t, 4, y)
+
+		grid.MoveBy(0, -25)
+		_, y = grid.CellPosition()
+		assert.Equal(t, 0, y)
+	})
+
+	t.Run("should move to the nearest visible cell", func(t *testing.T) {
+		grid := newGrid()
+
+		grid.MoveTo(1, 2)
+		x, y := grid.CellPosition()
+		assert.Equal(t, 2, x)
+		assert.Equal(t, 3, y)
+	})
+}
+
+func TestGrid_RenderHidden(t *testing.T) {
+	t.Run("should not render hidden rows and columns", func(t *testing.T) {
+		driver := NewHeadlessDriver(32, 4)
+		grid := NewGrid(&testGridModel{
+			values: [][]string{
+				{"a0", "b0", "c0"},
+				{"a1", "b1", "c1"},
+				{"a2", "b2", "c2"},
+			},
+			hiddenRows: map[int]bool{1: true},
+			hiddenCols: map[int]bool{1: true},
+		})
+
+		redraw(t, driver, grid)
+
+		assert.Equal(t, "        0       2       3", driver.ScreenLine(0))
+		assert.Equal(t, "0       a0      c0      ~", driver.ScreenLine(1))
+		assert.Equal(t, "2       a2      c2      ~", driver.ScreenLine(2))
+		assert.Equal(t, "3       ~       ~       ~", driver.ScreenLine(3))
+	})
+}
+
+type testGridModel struct {
+	values     [][]string
+	hiddenRows map[int]bool
+	hiddenCols map[int]bool
+}
+
+func (m *testGridModel) Dimensions() (int, int) {
+	return len(m.values[0]), len(m.values)
+}
+
+func (m *testGridModel) ColWidth(col int) int {
+	if m.hiddenCols[col] {
+		return 0
+	}
+	return 8
+}
+
+func (m *testGridModel) RowHeight(row int) int {
+	if m.hiddenRows[row] {
+		return 0
+	}
+	return 1
+}
+
+func (m *testGridModel) CellValue(x, y int) string {
+	return m.values[y][x]
+}
+
+func (m *testGridModel) CellAttributes(int, int) (fg, bg Attribute) {
+	return 0, 0
+}
+
+func TestGrid_RenderHeader(t *testing.T) {
+	newModel := func() *testHeaderGridModel {
+		values := make([][]string, 10)
+		values[0] = []string{"name", "qty", "team", "city"}
+		for r := 1; r < len(values); r++ {
+			values[r] = []string{"n" + strconv.Itoa(r), "q" + strconv.Itoa(r), "t" + strconv.Itoa(r), "c" + strconv.Itoa(r)}
+		}
+		return &testHeaderGridModel{testGridModel: testGridModel{values: values}, headerRows: 1, headerCols: 1}
+	}
+
+	t.Run("should label columns with the header", func(t *testing.T) {
+		driver := NewHeadlessDriver(32, 4)
+		grid := NewGrid(newModel())
+
+		redraw(t, driver, grid)
+
+		assert.Equal(t, "        name    qty     team", driver.ScreenLine(0))
+		assert.Equal(t, "0       name    qty     team", driver.ScreenLine(1))
+		assert.Equal(t, "1       n1      q1      t1", driver.ScreenLine(2))
+	})
+
+	t.Run("should keep the header rows and columns on screen while scrolling", func(t *testing.T) {
+		driver := NewHeadlessDriver(32, 6)
+		ui, err := NewUIWithDriver(driver)
+		assert.NoError(t, err)
+
+		grid := NewGrid(newModel())
+		ui.SetRootComponent(grid)
+		ui.Redraw()
+
+		grid.MoveTo(3, 8)
+		ui.Redraw()
+
+		assert.Equal(t, "        name    city    4", driver.ScreenLine(0))
+		assert.Equal(t, "0       name    city    ~", driver.ScreenLine(1))
+		assert.Equal(t, "6       n6      c6      ~", driver.ScreenLine(2))
+		assert.Equal(t, "7       n7      c7      ~", driver.ScreenLine(3))
+		assert.Equal(t, "8       n8      c8      ~", driver.ScreenLine(4))
+		assert.Equal(t, "9       n9      c9      ~", driver.ScreenLine(5))
+
+		grid.MoveTo(0, 0)
+		ui.Redraw()
+
+		assert.Equal(t, "0       name    qty     team", driver.ScreenLine(1))
+		assert.Equal(t, "1       n1      q1      t1", driver.ScreenLine(2))
+	})
+}
+
+type testHeaderGridModel struct {
+	testGridModel
+	headerRows, headerCols int
+}
+
+func (m *testHeaderGridModel) HeaderDimensions() (int, int) {
+	return m.headerCols, m.headerRows
+}
+
+func (m *testHeaderGridModel) ColLabel(col int) string {
+	return m.values[0][col]
+}
@@ -0,0 +1,171 @@
+// A headless driver, which renders to an in-memory buffer
+
+package ui
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// A cell of the headless driver screen buffer
+type HeadlessCell struct {
+	Ch     rune
+	Fg, Bg Attribute
+}
+
+// A driver which renders to an in-memory buffer instead of a terminal.  Key events are queued
+// by the caller, and once all the queued events have been processed the UI loop will return.
+// This makes it suitable for testing.
+type HeadlessDriver struct {
+	width, height int
+
+	cells  []HeadlessCell // Cells set since the last sync
+	screen []HeadlessCell // Cells as of the last sync
+
+	cursorX, cursorY int
+	cursorVisible    bool
+
+	eventsMutex sync.Mutex
+	events      []Event
+}
+
+// Creates a new headless driver with a screen of the given size.
+func NewHeadlessDriver(width, height int) *HeadlessDriver {
+	return &HeadlessDriver{width: width, height: height}
+}
+
+// Initializes the driver.  Returns an error if there was an error
+func (hd *HeadlessDriver) Init() error {
+	hd.cells = hd.newBuffer()
+	hd.screen = hd.newBuffer()
+	return nil
+}
+
+// Closes the driver
+func (hd *HeadlessDriver) Close() {
+}
+
+// Returns the size of the window.
+func (hd *HeadlessDriver) Size() (int, int) {
+	return hd.width, hd.height
+}
+
+// Sets the value of a specific cell
+func (hd *HeadlessDriver) SetCell(x, y int, ch rune, fg, bg Attribute) {
+	if x >= 0 && y >= 0 && x < hd.width && y < hd.height {
+		hd.cells[y*hd.width+x] = HeadlessCell{ch, fg, bg}
+	}
+}
+
+// Synchronizes the internal buffer with the real buffer
+func (hd *HeadlessDriver) Sync() {
+	copy(hd.screen, hd.cells)
+}
+
+// Returns the next queued event.  Once there are no more events, returns an EventQuit event.
+func (hd *HeadlessDriver) WaitForEvent() Event {
+	hd.eventsMutex.Lock()
+	defer hd.eventsMutex.Unlock()
+
+	if len(hd.events) == 0 {
+		return Event{Type: EventQuit}
+	}
+
+	event := hd.events[0]
+	hd.events = hd.events[1:]
+	return event
+}
+
+// Move the position of the cursor
+func (hd *HeadlessDriver) SetCursor(x, y int) {
+	hd.cursorX, hd.cursorY = x, y
+	hd.cursorVisible = true
+}
+
+// Hide the cursor
+func (hd *HeadlessDriver) HideCursor() {
+	hd.cursorVisible = false
+}
+
+// Queues an interrupt event
+func (hd *HeadlessDriver) Interrupt() {
+	hd.pushEvent(Event{Type: EventInterrupt})
+}
+
+// Queues a key press event with the given modifiers
+func (hd *HeadlessDriver) PushKey(key rune, mod int) {
+	hd.pushEvent(Event{EventKeyPress, mod, key})
+}
+
+// Queues a key press event for each rune of the string
+func (hd *HeadlessDriver) PushKeys(keys string) {
+	for _, key := range keys {
+		hd.PushKey(key, 0)
+	}
+}
+
+// Queues a resize event.  The new size will take effect once the event is processed.
+func (hd *HeadlessDriver) Resize(width, height int) {
+	hd.width, hd.height = width, height
+	hd.cells = hd.newBuffer()
+	hd.screen = hd.newBuffer()
+	hd.pushEvent(Event{Type: EventResize})
+}
+
+func (hd *HeadlessDriver) pushEvent(event Event) {
+	hd.eventsMutex.Lock()
+	defer hd.eventsMutex.Unlock()
+
+	hd.events = append(hd.events, event)
+}
+
+// Returns the cell at the given position of the screen, as of the last sync
+func (hd *HeadlessDriver) Cell(x, y int) HeadlessCell {
+	if x >= 0 && y >= 0 && x < hd.width && y < hd.height {
+		return hd.screen[y*hd.width+x]
+	}
+	return HeadlessCell{}
+}
+
+// Returns a line of the screen, as of the last sync, with trailing spaces removed.  As with a terminal,
+// the cell following a wide rune is covered by it and does not appear in the line.
+func (hd *HeadlessDriver) ScreenLine(y int) string {
+	if y < 0 || y >= hd.height {
+		return ""
+	}
+
+	line := new(strings.Builder)
+	cells := hd.screen[y*hd.width : (y+1)*hd.width]
+	for x := 0; x < len(cells); x++ {
+		if cells[x].Ch == 0 {
+			line.WriteRune(' ')
+			continue
+		}
+
+		line.WriteRune(cells[x].Ch)
+		if runewidth.RuneWidth(cells[x].Ch) > 1 {
+			x++
+		}
+	}
+	return strings.TrimRight(line.String(), " ")
+}
+
+// Returns the lines of the screen, as of the last sync
+func (hd *HeadlessDriver) ScreenLines() []string {
+	lines := make([]string, hd.height)
+	for y := range lines {
+		lines[y] = hd.ScreenLine(y)
+	}
+	return lines
+}
+
+// Returns the position of the cursor, and whether it is visible
+func (hd *HeadlessDriver) CursorPosition() (int, int, bool) {
+	return hd.cursorX, hd.cursorY, hd.cursorVisible
+}
+
+func (hd *HeadlessDriver) newBuffer() []HeadlessCell {
+	return make([]HeadlessCell, hd.width*hd.height)
+}
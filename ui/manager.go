@@ -3,6 +3,8 @@
 
 package ui
 
+import "sync"
+
 // The UI manager
 type Ui struct {
 	// The root component
@@ -12,12 +14,22 @@ type Ui struct {
 	drawContext *DrawContext
 	driver      Driver
 	shutdown    bool
+	closed      bool
+
+	// Functions posted from other goroutines, waiting to be called from the UI loop
+	postedMutex sync.Mutex
+	posted      []func()
 }
 
 // Creates a new UI context.  This also initializes the UI state.
 // Returns the context and an error.
 func NewUI() (*Ui, error) {
-	driver := &TermboxDriver{}
+	return NewUIWithDriver(&TermboxDriver{})
+}
+
+// Creates a new UI context using a specific driver.  This also initializes the driver.
+// Returns the context and an error.
+func NewUIWithDriver(driver Driver) (*Ui, error) {
 	err := driver.Init()
 
 	if err != nil {
@@ -30,9 +42,12 @@ func NewUI() (*Ui, error) {
 	return ui, nil
 }
 
-// Closes the UI context.
+// Closes the UI context.  Closing a context which has already been closed does nothing.
 func (ui *Ui) Close() {
-	ui.driver.Close()
+	if !ui.closed {
+		ui.driver.Close()
+		ui.closed = true
+	}
 }
 
 // Sets the root component
@@ -68,11 +83,36 @@ func (ui *Ui) Shutdown() {
 	ui.shutdown = true
 }
 
+// Post queues a function to be called from the UI loop, followed by a redraw.  This can be called from
+// any goroutine.
+func (ui *Ui) Post(fn func()) {
+	ui.postedMutex.Lock()
+	ui.posted = append(ui.posted, fn)
+	ui.postedMutex.Unlock()
+
+	ui.driver.Interrupt()
+}
+
+// Calls the functions which have been posted
+func (ui *Ui) runPosted() {
+	ui.postedMutex.Lock()
+	posted := ui.posted
+	ui.posted = nil
+	ui.postedMutex.Unlock()
+
+	for _, fn := range posted {
+		fn()
+	}
+}
+
 // Enter the UI loop
 func (ui *Ui) Loop() {
 	for !ui.shutdown {
 		ui.Redraw()
 		event := ui.driver.WaitForEvent()
+		if event.Type == EventQuit {
+			return
+		}
 
 		// TODO: If the event is a key-press, do something.
 		if event.Type == EventKeyPress {
@@ -83,6 +123,8 @@ func (ui *Ui) Loop() {
 
 			// HACK: Find another way to refresh the size of the screen to prevent a full redraw.
 			ui.driver.Sync()
+		} else if event.Type == EventInterrupt {
+			ui.runPosted()
 		}
 	}
 }
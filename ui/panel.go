@@ -0,0 +1,127 @@
+// Overlay panels
+
+package ui
+
+import (
+	"fmt"
+)
+
+// A layout which draws an overlay component over a base component.  The overlay is given the size
+// of the base component less the margins, and is centered within the base component at the size it
+// measures itself to be.  If there is no overlay, only the base component is drawn.
+type OverlayLayout struct {
+	Base    UiComponent
+	Overlay UiComponent
+
+	// The space left around the overlay
+	MarginX, MarginY int
+}
+
+func (ol *OverlayLayout) Remeasure(w, h int) (int, int) {
+	if ol.Base != nil {
+		ol.Base.Remeasure(w, h)
+	}
+	return w, h
+}
+
+func (ol *OverlayLayout) Redraw(context *DrawContext) {
+	if ol.Base != nil {
+		ol.Base.Redraw(context)
+	}
+
+	if ol.Overlay != nil {
+		// The overlay is measured here, as the layout may be redrawn without being remeasured
+		w, h := ol.Overlay.Remeasure(intMax(context.W-2*ol.MarginX, 0), intMax(context.H-2*ol.MarginY, 0))
+		w, h = intMin(w, context.W), intMin(h, context.H)
+		ol.Overlay.Redraw(context.NewSubContext((context.W-w)/2, (context.H-h)/2, w, h))
+	}
+}
+
+// A panel of lines of text within a border, which can be scrolled using the arrow and page keys.  The
+// panel is closed by pressing q, Esc or Enter.
+type TextPanel struct {
+	Title string
+	Lines []string
+
+	// Called when the panel is closed
+	OnClose func()
+
+	// The index of the first line shown, and the number of lines which fit within the border
+	scrollY      int
+	visibleLines int
+}
+
+// Measures the panel to fit the lines, up to the available space
+func (tp *TextPanel) Remeasure(w, h int) (int, int) {
+	width := TextWidth(tp.Title) + 4
+	for _, line := range tp.Lines {
+		width = intMax(width, TextWidth(line)+4)
+	}
+
+	tp.visibleLines = intMax(intMin(len(tp.Lines), h-2), 0)
+	tp.scrollTo(tp.scrollY)
+	return intMin(width, w), tp.visibleLines + 2
+}
+
+func (tp *TextPanel) Redraw(context *DrawContext) {
+	context.SetFgAttr(0)
+	context.SetBgAttr(0)
+	for y := 0; y < context.H; y++ {
+		context.HorizRule(y, ' ')
+	}
+
+	// Draw the border
+	w, h := context.W, context.H
+	context.HorizRule(0, '─')
+	context.HorizRule(h-1, '─')
+	for y := 1; y < h-1; y++ {
+		context.DrawRune(0, y, '│')
+		context.DrawRune(w-1, y, '│')
+	}
+	context.DrawRune(0, 0, '┌')
+	context.DrawRune(w-1, 0, '┐')
+	context.DrawRune(0, h-1, '└')
+	context.DrawRune(w-1, h-1, '┘')
+
+	if tp.Title != "" {
+		titleContext := context.NewSubContext(2, 0, w-4, 1)
+		titleContext.SetFgAttr(AttrBold)
+		titleContext.Print(0, 0, " "+tp.Title+" ")
+	}
+	if tp.visibleLines < len(tp.Lines) {
+		position := fmt.Sprintf(" %d-%d of %d ", tp.scrollY+1, tp.scrollY+tp.visibleLines, len(tp.Lines))
+		context.PrintRight(w-2, h-1, position)
+	}
+
+	lineContext := context.NewSubContext(2, 1, w-4, h-2)
+	for y := 0; y < tp.visibleLines; y++ {
+		lineContext.Print(0, y, tp.Lines[tp.scrollY+y])
+	}
+	context.HideCursor()
+}
+
+func (tp *TextPanel) KeyPressed(key rune, mod int) {
+	switch key {
+	case KeyArrowUp:
+		tp.scrollTo(tp.scrollY - 1)
+	case KeyArrowDown:
+		tp.scrollTo(tp.scrollY + 1)
+	case KeyPgup:
+		tp.scrollTo(tp.scrollY - tp.visibleLines)
+	case KeyPgdn, ' ':
+		tp.scrollTo(tp.scrollY + tp.visibleLines)
+	case KeyHome:
+		tp.scrollTo(0)
+	case KeyEnd:
+		tp.scrollTo(len(tp.Lines))
+	case 'q', KeyEsc, KeyEnter, KeyCtrlC:
+		if tp.OnClose != nil {
+			tp.OnClose()
+		}
+	}
+}
+
+// Scrolls the panel so that the line is the first one shown, keeping the panel filled with lines
+func (tp *TextPanel) scrollTo(line int) {
+	tp.scrollY = intMinMax(line, 0, intMax(len(tp.Lines)-tp.visibleLines, 0))
+}
@@ -0,0 +1,79 @@
+package ui
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTextPanel(t *testing.T) {
+	t.Run("should draw the lines within a border", func(t *testing.T) {
+		driver := NewHeadlessDriver(12, 4)
+		tp := &TextPanel{Title: "Help", Lines: []string{"one", "two"}}
+
+		redraw(t, driver, tp)
+
+		assert.Equal(t, []string{
+			"┌─ Help ───┐",
+			"│ one      │",
+			"│ two      │",
+			"└──────────┘",
+		}, driver.ScreenLines())
+	})
+
+	t.Run("should scroll lines which do not fit", func(t *testing.T) {
+		driver := NewHeadlessDriver(16, 4)
+		tp := &TextPanel{Lines: []string{"a", "b", "c", "d", "e"}}
+		redraw(t, driver, tp)
+
+		assert.Equal(t, "│ a            │", driver.ScreenLine(1))
+		assert.Equal(t, "└─── 1-2 of 5 ─┘", driver.ScreenLine(3))
+
+		tp.KeyPressed(KeyArrowDown, 0)
+		redraw(t, driver, tp)
+		assert.Equal(t, "│ b            │", driver.ScreenLine(1))
+
+		tp.KeyPressed(KeyEnd, 0)
+		redraw(t, driver, tp)
+		assert.Equal(t, "│ d            │", driver.ScreenLine(1))
+		assert.Equal(t, "└─── 4-5 of 5 ─┘", driver.ScreenLine(3))
+
+		tp.KeyPressed(KeyPgup, 0)
+		tp.KeyPressed(KeyPgup, 0)
+		redraw(t, driver, tp)
+		assert.Equal(t, "│ a            │", driver.ScreenLine(1))
+	})
+
+	t.Run("should call OnClose when closed", func(t *testing.T) {
+		closed := 0
+		tp := &TextPanel{OnClose: func() { closed++ }}
+
+		tp.KeyPressed('q', 0)
+		tp.KeyPressed(KeyEsc, 0)
+		tp.KeyPressed('x', 0)
+
+		assert.Equal(t, 2, closed)
+	})
+}
+
+func TestOverlayLayout_Redraw(t *testing.T) {
+	t.Run("should center the overlay over the base", func(t *testing.T) {
+		driver := NewHeadlessDriver(12, 5)
+		ol := &OverlayLayout{
+			Base:    &TextView{Text: "base"},
+			Overlay: &TextPanel{Lines: []string{"x"}},
+			MarginX: 2,
+			MarginY: 1,
+		}
+
+		redraw(t, driver, ol)
+
+		assert.Equal(t, []string{
+			"base",
+			"   ┌───┐",
+			"   │ x │",
+			"   └───┘",
+			"",
+		}, driver.ScreenLines())
+	})
+}
@@ -3,7 +3,9 @@
 package ui
 
 import (
+	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 // A text component.  This simply renders a text string.
@@ -50,14 +52,95 @@ func (sbar *StatusBar) Redraw(context *DrawContext) {
 	context.PrintRight(context.W, 0, sbar.Right)
 }
 
+// A candidate returned by a completer
+type Completion struct {
+	// The text which replaces the text being completed
+	Text string
+
+	// The label shown in the list of candidates.  If empty, the text is shown.
+	Label string
+}
+
+// Returns the label of the completion
+func (c Completion) String() string {
+	if c.Label != "" {
+		return c.Label
+	}
+	return c.Text
+}
+
+// Completes the text before the cursor of a text entry.  Returns the byte offset of the start of the text
+// being completed, and the candidates which can replace it.
+type Completer func(text string) (start int, candidates []Completion)
+
+// The maximum number of rows shown by a completion list
+const maxCompletionListRows = 5
+
+// A list of completion candidates, laid out in columns.  This takes no space if there are no candidates.
+type CompletionList struct {
+	Candidates []Completion
+
+	// The index of the selected candidate, or -1 if no candidate is selected
+	Selected int
+}
+
+// Returns the number of columns, and their width, needed to show the candidates in the given width
+func (cl *CompletionList) columns(w int) (cols int, colWidth int) {
+	for _, c := range cl.Candidates {
+		colWidth = intMax(colWidth, TextWidth(c.String())+2)
+	}
+	colWidth = intMin(colWidth, w)
+	return intMax(w/intMax(colWidth, 1), 1), colWidth
+}
+
+func (cl *CompletionList) Remeasure(w, h int) (int, int) {
+	if len(cl.Candidates) == 0 {
+		return w, 0
+	}
+
+	cols, _ := cl.columns(w)
+	rows := (len(cl.Candidates) + cols - 1) / cols
+	return w, intMin(intMin(rows, maxCompletionListRows), h)
+}
+
+func (cl *CompletionList) Redraw(context *DrawContext) {
+	cols, colWidth := cl.columns(context.W)
+
+	// Show the page of candidates containing the selected one
+	pageSize := cols * context.H
+	first := 0
+	if cl.Selected > 0 && pageSize > 0 {
+		first = cl.Selected / pageSize * pageSize
+	}
+
+	context.SetFgAttr(0)
+	context.SetBgAttr(0)
+	for y := 0; y < context.H; y++ {
+		context.HorizRule(y, ' ')
+	}
+
+	for i := first; i < len(cl.Candidates) && i < first+pageSize; i++ {
+		x, y := (i-first)%cols*colWidth, (i-first)/cols
+		cellContext := context.NewSubContext(x, y, colWidth-1, 1)
+		if i == cl.Selected {
+			cellContext.SetFgAttr(AttrReverse)
+			cellContext.SetBgAttr(AttrReverse)
+		}
+		cellContext.Print(0, 0, cl.Candidates[i].String())
+	}
+}
+
 // A single-text entry component.
 type TextEntry struct {
 	Prompt string
 
-	value         string
+	value []rune
+
+	// The positions of the cursor and the start of the displayed value, in screen columns
 	cursorOffset  int
 	displayOffset int
-	isDirty 	  bool
+
+	isDirty bool
 
 	// CancelOnEmptyBackspace will cancel the text entry prompt if no other
 	// key was pressed and the prompt was empty.
@@ -68,10 +151,31 @@ type TextEntry struct {
 
 	// Called when the user presses Esc or CtrlC
 	OnCancel func()
+
+	// Previous entries, oldest first, which can be recalled using the up and down arrows.  Only the
+	// entries starting with the value typed before recalling are recalled.
+	History []string
+
+	// The index of the recalled entry, or the length of the history if no entry is recalled
+	historyIndex int
+	historyDraft []rune
+
+	// Completes the value when the user presses Tab.  Pressing Tab again cycles through the candidates,
+	// which are shown in the completion list, if set.
+	Completer      Completer
+	CompletionList *CompletionList
+
+	completions     []Completion
+	completionStart int
+	completionIndex int
 }
 
+// Reset resets the state of the entry.  This should be called after changing the history.
 func (te *TextEntry) Reset() {
 	te.isDirty = false
+	te.historyIndex = len(te.History)
+	te.historyDraft = nil
+	te.clearCompletions()
 }
 
 func (te *TextEntry) Remeasure(w, h int) (int, int) {
@@ -88,18 +192,20 @@ func (te *TextEntry) Redraw(context *DrawContext) {
 		context.Print(0, 0, te.Prompt)
 		context.SetFgAttr(ColorDefault)
 
-		valueOffsetX = len(te.Prompt)
+		valueOffsetX = TextWidth(te.Prompt)
 	}
 
-	context.Print(valueOffsetX, 0, te.value[displayOffsetX:intMin(displayOffsetX+context.W, len(te.value))])
+	value := string(te.value)
+	context.Print(valueOffsetX, 0, value[offsetAtColumn(value, displayOffsetX):])
 	context.SetCursorPosition(te.cursorOffset+valueOffsetX-displayOffsetX, 0)
 
 	//context.Print(0, 0, fmt.Sprintf("%d,%d", te.cursorOffset, displayOffsetX))
 }
 
+// Calculates the column of the value displayed at the start of the entry, so that the cursor is visible.
 func (te *TextEntry) calculateDisplayOffset(displayWidth int) int {
 	if te.Prompt != "" {
-		displayWidth -= len(te.Prompt)
+		displayWidth -= TextWidth(te.Prompt)
 	}
 	virtualCursorOffset := te.cursorOffset - te.displayOffset
 
@@ -109,26 +215,38 @@ func (te *TextEntry) calculateDisplayOffset(displayWidth int) int {
 		te.displayOffset = intMax(te.cursorOffset-displayWidth+1, 0)
 	}
 
+	// Avoid starting the display in the middle of a wide character
+	for _, c := range textClusters(te.value) {
+		if c.col >= te.displayOffset {
+			te.displayOffset = c.col
+			break
+		}
+	}
+
 	return te.displayOffset
 }
 
 // SetValue sets the value of the text entry
 func (te *TextEntry) SetValue(val string) {
-	te.value = val
-	te.cursorOffset = len(val)
+	te.value = []rune(val)
+	te.cursorOffset = TextWidth(val)
 }
 
 func (te *TextEntry) KeyPressed(key rune, mod int) {
-	if (key >= ' ') && (key <= '~') {
+	if key != KeyCtrlI {
+		te.clearCompletions()
+	}
+
+	if unicode.IsPrint(key) {
 		te.insertRune(key)
 	} else if key == KeyArrowLeft {
 		te.moveCursorBy(-1)
 	} else if key == KeyArrowRight {
 		te.moveCursorBy(1)
 	} else if (key == KeyHome) || (key == KeyCtrlA) {
-		te.moveCursorTo(0)
+		te.moveCursorToCluster(0)
 	} else if (key == KeyEnd) || (key == KeyCtrlE) {
-		te.moveCursorTo(len(te.value))
+		te.moveCursorToCluster(len(textClusters(te.value)))
 	} else if (key == KeyBackspace) || (key == KeyBackspace2) {
 		if mod&ModKeyAlt != 0 {
 			te.backspaceWhile(unicode.IsSpace)
@@ -143,18 +261,116 @@ func (te *TextEntry) KeyPressed(key rune, mod int) {
 	} else if key == KeyCtrlK {
 		te.killLine()
 	} else if key == KeyDelete {
-		te.removeCharAtPos(te.cursorOffset)
+		te.removeCluster(te.cursorCluster())
 	} else if key == KeyEnter {
 		if te.OnEntry != nil {
-			te.OnEntry(te.value)
+			te.OnEntry(string(te.value))
 		}
 	} else if key == KeyCtrlC {
 		te.cancelAndExit()
+	} else if key == KeyArrowUp {
+		te.recallHistory(-1)
+	} else if key == KeyArrowDown {
+		te.recallHistory(1)
+	} else if key == KeyCtrlI {
+		te.complete()
 	}
 
 	//panic(fmt.Sprintf("Entered key: '%x', mod: '%x'", key, mod))
 }
 
+// Recalls the previous or next entry of the history which starts with the value typed before recalling.
+// Moving past the newest entry restores the typed value.
+func (te *TextEntry) recallHistory(dir int) {
+	if te.historyIndex >= len(te.History) {
+		te.historyIndex = len(te.History)
+		te.historyDraft = te.value
+	}
+
+	prefix := string(te.historyDraft)
+	for i := te.historyIndex + dir; i >= 0 && i < len(te.History); i += dir {
+		if strings.HasPrefix(te.History[i], prefix) {
+			te.historyIndex = i
+			te.SetValue(te.History[i])
+			te.isDirty = true
+			return
+		}
+	}
+
+	if dir > 0 && te.historyIndex < len(te.History) {
+		te.historyIndex = len(te.History)
+		te.SetValue(string(te.historyDraft))
+	}
+}
+
+// Completes the text before the cursor.  A single candidate replaces the text.  If there are several,
+// the text is replaced with their common prefix and the candidates are listed.  Completing again will
+// cycle through the candidates.
+func (te *TextEntry) complete() {
+	if len(te.completions) > 1 {
+		te.completionIndex = (te.completionIndex + 1) % len(te.completions)
+		te.replaceCompletion(te.completions[te.completionIndex].Text)
+		if te.CompletionList != nil {
+			te.CompletionList.Selected = te.completionIndex
+		}
+		return
+	}
+
+	if te.Completer == nil {
+		return
+	}
+	before := string(te.value[:te.cursorRuneIndex()])
+	start, candidates := te.Completer(before)
+	if len(candidates) == 0 {
+		return
+	}
+
+	te.completionStart = utf8.RuneCountInString(before[:start])
+	if len(candidates) == 1 {
+		te.replaceCompletion(candidates[0].Text)
+		return
+	}
+
+	te.replaceCompletion(completionsPrefix(candidates))
+	te.completions = candidates
+	te.completionIndex = -1
+	if te.CompletionList != nil {
+		te.CompletionList.Candidates = candidates
+		te.CompletionList.Selected = -1
+	}
+}
+
+// Replaces the text between the start of the completion and the cursor
+func (te *TextEntry) replaceCompletion(text string) {
+	te.isDirty = true
+	after := te.value[te.cursorRuneIndex():]
+	value := append(append(te.value[:te.completionStart:te.completionStart], []rune(text)...), after...)
+
+	te.value = value
+	te.cursorOffset = textClustersWidth(textClusters(value[:len(value)-len(after)]))
+}
+
+func (te *TextEntry) clearCompletions() {
+	te.completions = nil
+	if te.CompletionList != nil {
+		te.CompletionList.Candidates = nil
+	}
+}
+
+// Returns the longest prefix common to the text of all the completions
+func completionsPrefix(completions []Completion) string {
+	prefix := []rune(completions[0].Text)
+	for _, c := range completions[1:] {
+		text := []rune(c.Text)
+		n := 0
+		for n < len(prefix) && n < len(text) && prefix[n] == text[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	return string(prefix)
+}
+
 func (te *TextEntry) cancelAndExit() {
 	if te.OnCancel != nil {
 		te.OnCancel()
@@ -163,14 +379,17 @@ func (te *TextEntry) cancelAndExit() {
 
 // Backspace
 func (te *TextEntry) backspace() {
-	te.removeCharAtPos(te.cursorOffset - 1)
-	te.moveCursorBy(-1)
+	if cluster := te.cursorCluster(); cluster > 0 {
+		te.removeCluster(cluster - 1)
+		te.moveCursorToCluster(cluster - 1)
+	}
 }
 
 // Backspace while the character underneith the cursor matches the guard
 func (te *TextEntry) backspaceWhile(guard func(r rune) bool) {
 	for te.cursorOffset > 0 {
-		ch := rune(te.value[te.cursorOffset-1])
+		cs := textClusters(te.value)
+		ch := te.value[cs[te.cursorCluster()-1].start]
 		if guard(ch) {
 			te.backspace()
 		} else {
@@ -183,38 +402,71 @@ func (te *TextEntry) backspaceWhile(guard func(r rune) bool) {
 // Otherwise, trim the line.
 func (te *TextEntry) killLine() {
 	te.isDirty = true
-	if te.cursorOffset < len(te.value) {
-		te.value = te.value[:te.cursorOffset]
+	if pos := te.cursorRuneIndex(); pos < len(te.value) {
+		te.value = te.value[:pos]
 	} else {
-		te.value = ""
+		te.value = nil
 		te.cursorOffset = 0
 	}
 }
 
-// Inserts a rune at the cursor position
+// Inserts a rune at the cursor position.  The cursor is moved past the grapheme cluster containing
+// the rune, which may be a cluster preceding the cursor if the rune is a combining mark.
 func (te *TextEntry) insertRune(key rune) {
 	te.isDirty = true
-	if te.cursorOffset >= len(te.value) {
-		te.value += string(key)
-	} else {
-		te.value = te.value[:te.cursorOffset] + string(key) + te.value[te.cursorOffset:]
+	pos := te.cursorRuneIndex()
+	te.value = append(te.value[:pos], append([]rune{key}, te.value[pos:]...)...)
+
+	for _, c := range textClusters(te.value) {
+		if c.end > pos {
+			te.cursorOffset = c.col + c.width
+			return
+		}
 	}
-	te.moveCursorBy(1)
 }
 
-// Remove the character at a specific position
-func (te *TextEntry) removeCharAtPos(pos int) {
+// Remove the grapheme cluster with a specific index
+func (te *TextEntry) removeCluster(cluster int) {
 	te.isDirty = true
-	if (pos >= 0) && (pos < len(te.value)) {
-		te.value = te.value[:pos] + te.value[pos+1:]
+	cs := textClusters(te.value)
+	if (cluster >= 0) && (cluster < len(cs)) {
+		te.value = append(te.value[:cs[cluster].start], te.value[cs[cluster].end:]...)
+	}
+}
+
+// Returns the index of the grapheme cluster at the cursor.  This is the number of clusters if the
+// cursor is at the end of the value.
+func (te *TextEntry) cursorCluster() int {
+	cs := textClusters(te.value)
+	for i, c := range cs {
+		if c.col >= te.cursorOffset {
+			return i
+		}
 	}
+	return len(cs)
 }
 
-// Move the cursor
-func (te *TextEntry) moveCursorBy(byX int) {
-	te.moveCursorTo(te.cursorOffset + byX)
+// Returns the index of the rune at the cursor
+func (te *TextEntry) cursorRuneIndex() int {
+	cs := textClusters(te.value)
+	if cluster := te.cursorCluster(); cluster < len(cs) {
+		return cs[cluster].start
+	}
+	return len(te.value)
+}
+
+// Move the cursor by a number of grapheme clusters
+func (te *TextEntry) moveCursorBy(byClusters int) {
+	te.moveCursorToCluster(te.cursorCluster() + byClusters)
 }
 
-func (te *TextEntry) moveCursorTo(toX int) {
-	te.cursorOffset = intMinMax(toX, 0, len(te.value))
+// Move the cursor to the start of the grapheme cluster with the given index
+func (te *TextEntry) moveCursorToCluster(cluster int) {
+	cs := textClusters(te.value)
+	cluster = intMinMax(cluster, 0, len(cs))
+	if cluster < len(cs) {
+		te.cursorOffset = cs[cluster].col
+	} else {
+		te.cursorOffset = textClustersWidth(cs)
+	}
 }
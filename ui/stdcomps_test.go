@@ -0,0 +1,276 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTextEntry_KeyPressed(t *testing.T) {
+	t.Run("should accept non-ASCII characters", func(t *testing.T) {
+		te := &TextEntry{}
+
+		typeKeys(te, "héllo wörld 日本")
+
+		assert.Equal(t, "héllo wörld 日本", enteredValue(te))
+		assert.Equal(t, 16, te.cursorOffset)
+	})
+
+	t.Run("should not insert special keys", func(t *testing.T) {
+		te := &TextEntry{}
+
+		typeKeys(te, "ab")
+		te.KeyPressed(KeyArrowUp, 0)
+		te.KeyPressed(KeyF1, 0)
+		te.KeyPressed(KeyCtrlSpace, 0)
+
+		assert.Equal(t, "ab", enteredValue(te))
+	})
+
+	t.Run("should move the cursor by grapheme", func(t *testing.T) {
+		te := &TextEntry{}
+		te.SetValue("aé日b")
+		assert.Equal(t, 5, te.cursorOffset)
+
+		te.KeyPressed(KeyArrowLeft, 0)
+		assert.Equal(t, 4, te.cursorOffset)
+		te.KeyPressed(KeyArrowLeft, 0)
+		assert.Equal(t, 2, te.cursorOffset)
+		te.KeyPressed(KeyArrowLeft, 0)
+		assert.Equal(t, 1, te.cursorOffset)
+
+		te.KeyPressed(KeyArrowRight, 0)
+		assert.Equal(t, 2, te.cursorOffset)
+		te.KeyPressed(KeyHome, 0)
+		assert.Equal(t, 0, te.cursorOffset)
+		te.KeyPressed(KeyEnd, 0)
+		assert.Equal(t, 5, te.cursorOffset)
+	})
+
+	t.Run("should insert characters in the middle of the value", func(t *testing.T) {
+		te := &TextEntry{}
+		te.SetValue("日本")
+
+		te.KeyPressed(KeyArrowLeft, 0)
+		typeKeys(te, "ü")
+
+		assert.Equal(t, "日ü本", enteredValue(te))
+		assert.Equal(t, 3, te.cursorOffset)
+	})
+
+	t.Run("should keep combining marks with the preceding character", func(t *testing.T) {
+		te := &TextEntry{}
+
+		typeKeys(te, "é")
+		assert.Equal(t, 1, te.cursorOffset)
+
+		te.KeyPressed(KeyBackspace2, 0)
+		assert.Equal(t, "", enteredValue(te))
+		assert.Equal(t, 0, te.cursorOffset)
+	})
+
+	t.Run("should delete whole characters", func(t *testing.T) {
+		te := &TextEntry{}
+		te.SetValue("añ日b")
+
+		te.KeyPressed(KeyBackspace2, 0)
+		te.KeyPressed(KeyBackspace2, 0)
+		assert.Equal(t, "añ", enteredValue(te))
+		assert.Equal(t, 2, te.cursorOffset)
+
+		te.KeyPressed(KeyHome, 0)
+		te.KeyPressed(KeyDelete, 0)
+		assert.Equal(t, "ñ", enteredValue(te))
+		assert.Equal(t, 0, te.cursorOffset)
+	})
+
+	t.Run("should delete the previous word", func(t *testing.T) {
+		te := &TextEntry{}
+		te.SetValue("größe straße")
+
+		te.KeyPressed(KeyBackspace2, ModKeyAlt)
+
+		assert.Equal(t, "größe ", enteredValue(te))
+		assert.Equal(t, 6, te.cursorOffset)
+	})
+
+	t.Run("should kill the rest of the line", func(t *testing.T) {
+		te := &TextEntry{}
+		te.SetValue("日本語")
+
+		te.KeyPressed(KeyArrowLeft, 0)
+		te.KeyPressed(KeyCtrlK, 0)
+
+		assert.Equal(t, "日本", enteredValue(te))
+	})
+}
+
+func TestTextEntry_History(t *testing.T) {
+	t.Run("should recall previous entries and restore the typed value", func(t *testing.T) {
+		te := &TextEntry{History: []string{"save", "set header 1", "sort"}}
+		te.Reset()
+
+		typeKeys(te, "x")
+		te.KeyPressed(KeyArrowUp, 0)
+		assert.Equal(t, "x", string(te.value))
+
+		te.KeyPressed(KeyBackspace2, 0)
+		te.KeyPressed(KeyArrowUp, 0)
+		assert.Equal(t, "sort", string(te.value))
+		te.KeyPressed(KeyArrowUp, 0)
+		assert.Equal(t, "set header 1", string(te.value))
+		assert.Equal(t, 12, te.cursorOffset)
+		te.KeyPressed(KeyArrowDown, 0)
+		te.KeyPressed(KeyArrowDown, 0)
+
+		assert.Equal(t, "", enteredValue(te))
+	})
+
+	t.Run("should only recall entries starting with the typed value", func(t *testing.T) {
+		te := &TextEntry{History: []string{"save", "set header 1", "sort", "set backup true"}}
+		te.Reset()
+
+		typeKeys(te, "se")
+		te.KeyPressed(KeyArrowUp, 0)
+		assert.Equal(t, "set backup true", string(te.value))
+		te.KeyPressed(KeyArrowUp, 0)
+		assert.Equal(t, "set header 1", string(te.value))
+		te.KeyPressed(KeyArrowUp, 0)
+		assert.Equal(t, "set header 1", string(te.value))
+		te.KeyPressed(KeyArrowDown, 0)
+		te.KeyPressed(KeyArrowDown, 0)
+
+		assert.Equal(t, "se", enteredValue(te))
+	})
+}
+
+func TestTextEntry_Completion(t *testing.T) {
+	completer := func(text string) (int, []Completion) {
+		start := strings.LastIndex(text, " ") + 1
+		candidates := make([]Completion, 0)
+		for _, word := range []string{"save", "set", "sort", "日本"} {
+			if strings.HasPrefix(word, text[start:]) {
+				candidates = append(candidates, Completion{Text: word + " ", Label: word})
+			}
+		}
+		return start, candidates
+	}
+
+	t.Run("should complete a single candidate", func(t *testing.T) {
+		te := &TextEntry{Completer: completer, CompletionList: &CompletionList{}}
+
+		typeKeys(te, "x so")
+		te.KeyPressed(KeyCtrlI, 0)
+
+		assert.Equal(t, "x sort ", string(te.value))
+		assert.Empty(t, te.CompletionList.Candidates)
+	})
+
+	t.Run("should complete the common prefix then cycle through the candidates", func(t *testing.T) {
+		te := &TextEntry{Completer: completer, CompletionList: &CompletionList{}}
+
+		typeKeys(te, "s!")
+		te.KeyPressed(KeyArrowLeft, 0)
+		te.KeyPressed(KeyCtrlI, 0)
+		assert.Equal(t, "s!", string(te.value))
+		assert.Len(t, te.CompletionList.Candidates, 3)
+		assert.Equal(t, -1, te.CompletionList.Selected)
+
+		te.KeyPressed(KeyCtrlI, 0)
+		assert.Equal(t, "save !", string(te.value))
+		te.KeyPressed(KeyCtrlI, 0)
+		assert.Equal(t, "set !", string(te.value))
+		assert.Equal(t, 1, te.CompletionList.Selected)
+		assert.Equal(t, 4, te.cursorOffset)
+
+		typeKeys(te, "x")
+		assert.Empty(t, te.CompletionList.Candidates)
+		assert.Equal(t, "set x!", enteredValue(te))
+	})
+
+	t.Run("should complete wide characters", func(t *testing.T) {
+		te := &TextEntry{Completer: completer}
+
+		typeKeys(te, "日")
+		te.KeyPressed(KeyCtrlI, 0)
+
+		assert.Equal(t, "日本 ", string(te.value))
+		assert.Equal(t, 5, te.cursorOffset)
+	})
+}
+
+func TestCompletionList_Redraw(t *testing.T) {
+	t.Run("should lay out the candidates in columns", func(t *testing.T) {
+		driver := NewHeadlessDriver(22, 3)
+		cl := &CompletionList{Candidates: []Completion{{Text: "save"}, {Text: "set"}, {Text: "sort"}, {Text: "x", Label: "sort-desc"}}, Selected: 3}
+
+		redraw(t, driver, cl)
+
+		assert.Equal(t, "save       set", driver.ScreenLine(0))
+		assert.Equal(t, "sort       sort-desc", driver.ScreenLine(1))
+		assert.Equal(t, AttrReverse, driver.Cell(11, 1).Fg&AttrReverse)
+	})
+
+	t.Run("should show the page containing the selected candidate", func(t *testing.T) {
+		driver := NewHeadlessDriver(8, 2)
+		cl := &CompletionList{Candidates: []Completion{{Text: "a"}, {Text: "b"}, {Text: "c"}, {Text: "d"}, {Text: "e"}, {Text: "f"}}, Selected: 4}
+
+		redraw(t, driver, cl)
+
+		assert.Equal(t, "e  f", driver.ScreenLine(0))
+		assert.Equal(t, "", driver.ScreenLine(1))
+	})
+
+	t.Run("should take no space without candidates", func(t *testing.T) {
+		_, h := (&CompletionList{}).Remeasure(20, 10)
+		assert.Equal(t, 0, h)
+	})
+}
+
+func TestTextEntry_Redraw(t *testing.T) {
+	t.Run("should place the cursor by display width", func(t *testing.T) {
+		driver := NewHeadlessDriver(20, 1)
+		te := &TextEntry{Prompt: "値: "}
+		te.SetValue("日本é")
+
+		redraw(t, driver, te)
+
+		assert.Equal(t, "値: 日本é", driver.ScreenLine(0))
+		x, _, _ := driver.CursorPosition()
+		assert.Equal(t, 9, x)
+	})
+
+	t.Run("should scroll wide values to keep the cursor visible", func(t *testing.T) {
+		driver := NewHeadlessDriver(10, 1)
+		te := &TextEntry{}
+		te.SetValue("あいうえおかきくけこ")
+
+		ui, err := NewUIWithDriver(driver)
+		assert.NoError(t, err)
+		ui.SetRootComponent(te)
+		ui.Redraw()
+
+		te.KeyPressed(KeyArrowLeft, 0)
+		te.KeyPressed(KeyArrowLeft, 0)
+		te.KeyPressed(KeyArrowLeft, 0)
+		ui.Redraw()
+
+		assert.Equal(t, "えおかきく", driver.ScreenLine(0))
+		x, _, _ := driver.CursorPosition()
+		assert.Equal(t, 8, x)
+	})
+}
+
+func typeKeys(te *TextEntry, keys string) {
+	for _, key := range keys {
+		te.KeyPressed(key, 0)
+	}
+}
+
+func enteredValue(te *TextEntry) string {
+	var value string
+	te.OnEntry = func(val string) { value = val }
+	te.KeyPressed(KeyEnter, 0)
+	return value
+}
@@ -52,6 +52,8 @@ func (td *TermboxDriver) WaitForEvent() Event {
 	switch tev.Type {
 	case termbox.EventResize:
 		return Event{EventResize, 0, 0}
+	case termbox.EventInterrupt:
+		return Event{EventInterrupt, 0, 0}
 	case termbox.EventKey:
 		mod := 0
 		if tev.Mod&termbox.ModAlt != 0 {
@@ -69,6 +71,11 @@ func (td *TermboxDriver) WaitForEvent() Event {
 	}
 }
 
+// Interrupts a wait for an event
+func (td *TermboxDriver) Interrupt() {
+	termbox.Interrupt()
+}
+
 // Move the position of the cursor
 func (td *TermboxDriver) SetCursor(x, y int) {
 	termbox.SetCursor(x, y)
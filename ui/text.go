@@ -0,0 +1,153 @@
+// Utilities for measuring and laying out text for display
+
+package ui
+
+import (
+	"unicode"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/rivo/uniseg"
+)
+
+// The marker displayed at the end of text which has been clipped
+const ellipsis = '…'
+
+// A grapheme cluster of a string, as it will be displayed
+type glyph struct {
+	// The rune which will be drawn.  The driver can only draw a single rune per cell, so this is
+	// the first rune of the cluster.
+	ch rune
+
+	// The number of screen cells taken up by the cluster
+	width int
+
+	// The byte offset of the cluster within the string
+	offset int
+}
+
+// Splits a string into glyphs.  White space and control characters are displayed as a single space.
+// Clusters which take up no space, such as stray combining marks, are dropped.
+func glyphs(str string) []glyph {
+	gs := make([]glyph, 0, len(str))
+	gr := uniseg.NewGraphemes(str)
+	for gr.Next() {
+		runes := gr.Runes()
+		offset, _ := gr.Positions()
+
+		if isBlankRune(runes[0]) {
+			gs = append(gs, glyph{' ', 1, offset})
+			continue
+		}
+
+		width := runewidth.StringWidth(gr.Str())
+		if width == 0 {
+			continue
+		}
+		gs = append(gs, glyph{runes[0], width, offset})
+	}
+	return gs
+}
+
+// A grapheme cluster of editable text
+type textCluster struct {
+	// The rune indices of the start and end of the cluster
+	start, end int
+
+	// The column of the cluster and the number of screen cells it takes up
+	col, width int
+}
+
+// Splits runes into grapheme clusters.  Unlike glyphs, clusters which take up no space are kept, so
+// that every rune belongs to a cluster.
+func textClusters(runes []rune) []textCluster {
+	cs := make([]textCluster, 0, len(runes))
+	start, col := 0, 0
+
+	gr := uniseg.NewGraphemes(string(runes))
+	for gr.Next() {
+		clusterRunes := gr.Runes()
+
+		width := 1
+		if !isBlankRune(clusterRunes[0]) {
+			width = runewidth.StringWidth(gr.Str())
+		}
+
+		end := start + len(clusterRunes)
+		cs = append(cs, textCluster{start, end, col, width})
+		start, col = end, col+width
+	}
+	return cs
+}
+
+func textClustersWidth(cs []textCluster) int {
+	if len(cs) == 0 {
+		return 0
+	}
+	last := cs[len(cs)-1]
+	return last.col + last.width
+}
+
+// Returns true if the rune is displayed as a space
+func isBlankRune(r rune) bool {
+	return unicode.IsSpace(r) || unicode.IsControl(r)
+}
+
+// TextWidth returns the number of screen cells needed to display the string.
+func TextWidth(str string) int {
+	return textWidthOfGlyphs(glyphs(str))
+}
+
+// Lays out the string to fit within a number of screen cells.  The returned slice has a rune for each
+// cell, with 0 indicating a cell covered by the wide rune preceding it.  If the string does not fit, it
+// is clipped and, if clipMarker is true, ends with an ellipsis.  Wide runes are never split.
+func layoutText(str string, width int, clipMarker bool) []rune {
+	cells := make([]rune, width)
+	for i := range cells {
+		cells[i] = ' '
+	}
+
+	gs := glyphs(str)
+	available := width
+	if clipMarker && textWidthOfGlyphs(gs) > width {
+		available = width - 1
+	}
+
+	x := 0
+	for _, g := range gs {
+		if x+g.width > available {
+			if clipMarker && available < width {
+				cells[x] = ellipsis
+			}
+			return cells
+		}
+
+		cells[x] = g.ch
+		for i := 1; i < g.width; i++ {
+			cells[x+i] = 0
+		}
+		x += g.width
+	}
+	return cells
+}
+
+// Returns the byte offset of the glyph displayed at the given column of the string, or the length
+// of the string if the column is beyond the end.  If the column falls in the middle of a wide glyph,
+// the offset of the following glyph is returned.
+func offsetAtColumn(str string, col int) int {
+	x := 0
+	for _, g := range glyphs(str) {
+		if x >= col {
+			return g.offset
+		}
+		x += g.width
+	}
+	return len(str)
+}
+
+func textWidthOfGlyphs(gs []glyph) int {
+	width := 0
+	for _, g := range gs {
+		width += g.width
+	}
+	return width
+}
@@ -0,0 +1,82 @@
+package ui
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTextWidth(t *testing.T) {
+	scenarios := []struct {
+		text     string
+		expected int
+	}{
+		{"", 0},
+		{"hello", 5},
+		{"héllo", 5},
+		{"héllo", 5},
+		{"日本語", 6},
+		{"a\tb", 3},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.text, func(t *testing.T) {
+			assert.Equal(t, scenario.expected, TextWidth(scenario.text))
+		})
+	}
+}
+
+func TestLayoutText(t *testing.T) {
+	t.Run("should pad text which fits", func(t *testing.T) {
+		assert.Equal(t, []rune("héllo   "), layoutText("héllo", 8, true))
+	})
+
+	t.Run("should mark the cells covered by wide runes", func(t *testing.T) {
+		assert.Equal(t, []rune{'日', 0, '本', 0, ' '}, layoutText("日本", 5, true))
+	})
+
+	t.Run("should clip text with an ellipsis", func(t *testing.T) {
+		assert.Equal(t, []rune("hell…"), layoutText("hello world", 5, true))
+		assert.Equal(t, []rune("hello"), layoutText("hello world", 5, false))
+	})
+
+	t.Run("should not split wide runes when clipping", func(t *testing.T) {
+		assert.Equal(t, []rune{'日', 0, '…', ' '}, layoutText("日本語", 4, true))
+		assert.Equal(t, []rune{'日', 0, ' '}, layoutText("日本語", 3, false))
+	})
+}
+
+func TestGrid_Render(t *testing.T) {
+	t.Run("should render unicode values aligned by display width", func(t *testing.T) {
+		driver := NewHeadlessDriver(32, 4)
+		grid := NewGrid(&testGridModel{values: [][]string{
+			{"日本語", "x"},
+			{"héllo", "y"},
+			{"長い長い長い", "z"},
+		}})
+
+		redraw(t, driver, grid)
+
+		assert.Equal(t, "0       日本語  x       ~", driver.ScreenLine(1))
+		assert.Equal(t, "1       héllo   y       ~", driver.ScreenLine(2))
+		assert.Equal(t, "2       長い長… z       ~", driver.ScreenLine(3))
+	})
+}
+
+func TestStatusBar_Render(t *testing.T) {
+	t.Run("should right-justify wide text", func(t *testing.T) {
+		driver := NewHeadlessDriver(12, 1)
+
+		redraw(t, driver, &StatusBar{Left: "ü", Right: "日本"})
+
+		assert.Equal(t, "ü       日本", driver.ScreenLine(0))
+	})
+}
+
+func redraw(t *testing.T, driver *HeadlessDriver, comp UiComponent) {
+	ui, err := NewUIWithDriver(driver)
+	assert.NoError(t, err)
+
+	ui.SetRootComponent(comp)
+	ui.Redraw()
+}
@@ -2,12 +2,21 @@ package main
 
 import (
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lmika/ted/ui"
 )
 
 type ModelViewCtrl struct {
 	model    Model
 	rowAttrs []SliceAttr
 	colAttrs []SliceAttr
+	journal  changeJournal
+
+	// The number of header rows and columns
+	headerRows, headerCols int
 }
 
 func NewGridViewModel(model Model) *ModelViewCtrl {
@@ -22,6 +31,7 @@ func (gvm *ModelViewCtrl) Model() Model {
 
 func (gvm *ModelViewCtrl) SetModel(m Model) {
 	gvm.model = m
+	gvm.journal.reset()
 	gvm.modelWasResized()
 }
 
@@ -49,13 +59,70 @@ func (gvm *ModelViewCtrl) SetColAttrs(col int, newAttrs SliceAttr) {
 	}
 }
 
+// Header returns the number of header rows and columns.
+func (gvm *ModelViewCtrl) Header() (rows, cols int) {
+	return gvm.headerRows, gvm.headerCols
+}
+
+// SetHeader sets the number of header rows and columns.  The header rows are left in place when
+// sorting and filtering, and the first one provides the names of the columns.
+func (gvm *ModelViewCtrl) SetHeader(rows, cols int) {
+	gvm.headerRows, gvm.headerCols = rows, cols
+}
+
+// ColLabel returns the name of a column, which is its value in the first header row.  Returns the
+// empty string if there are no header rows.
+func (gvm *ModelViewCtrl) ColLabel(col int) string {
+	if gvm.headerRows == 0 {
+		return ""
+	}
+	return gvm.model.CellValue(0, col)
+}
+
+// ColumnIndex returns the index of a column referred to either by its name, as given by ColLabel,
+// or by its index.  Names are matched exactly if possible, otherwise ignoring case.
+func (gvm *ModelViewCtrl) ColumnIndex(ref string) (int, error) {
+	_, cols := gvm.model.Dimensions()
+	if gvm.headerRows > 0 {
+		foldedMatch := -1
+		for c := 0; c < cols; c++ {
+			label := gvm.ColLabel(c)
+			if label == ref {
+				return c, nil
+			} else if foldedMatch == -1 && strings.EqualFold(label, ref) {
+				foldedMatch = c
+			}
+		}
+		if foldedMatch != -1 {
+			return foldedMatch, nil
+		}
+	}
+
+	col, err := strconv.Atoi(ref)
+	if err != nil || col < 0 || col >= cols {
+		return 0, fmt.Errorf("no such column: %v", ref)
+	}
+	return col, nil
+}
+
 func (gvm *ModelViewCtrl) SetCellValue(r, c int, newValue string) error {
 	rwModel, isRWModel := gvm.model.(RWModel)
 	if !isRWModel {
 		return ErrModelReadOnly
 	}
 
+	rows, cols := rwModel.Dimensions()
+	if r < 0 || c < 0 || r >= rows || c >= cols {
+		return nil
+	}
+
+	oldValue := rwModel.CellValue(r, c)
+	if oldValue == newValue {
+		return nil
+	}
+
 	rwModel.SetCellValue(r, c, newValue)
+	gvm.journal.record(cellValueChange{row: r, col: c, oldValue: oldValue, newValue: newValue})
 	return nil
 }
 
@@ -65,8 +132,27 @@ func (gvm *ModelViewCtrl) Resize(newRow, newCol int) error {
 		return ErrModelReadOnly
 	}
 
-	rwModel.Resize(newRow, newCol)
-	gvm.modelWasResized()
+	oldRow, oldCol := rwModel.Dimensions()
+	change := resizeChange{oldRows: oldRow, oldCols: oldCol, newRows: newRow, newCols: newCol}
+	for r := 0; r < oldRow; r++ {
+		for c := 0; c < oldCol; c++ {
+			if r < newRow && c < newCol {
+				continue
+			}
+			if value := rwModel.CellValue(r, c); value != "" {
+				change.droppedCells = append(change.droppedCells, cellValueChange{row: r, col: c, oldValue: value})
+			}
+		}
+	}
+	if newRow < len(gvm.rowAttrs) {
+		change.droppedRowAttrs = append([]SliceAttr{}, gvm.rowAttrs[newRow:]...)
+	}
+	if newCol < len(gvm.colAttrs) {
+		change.droppedColAttrs = append([]SliceAttr{}, gvm.colAttrs[newCol:]...)
+	}
+
+	gvm.resizeModel(newRow, newCol)
+	gvm.journal.record(change)
 
 	return nil
 }
@@ -84,22 +170,14 @@ func (gvm *ModelViewCtrl) insertColumn(col int) error {
 		return ErrModelReadOnly
 	}
 
-	dr, dc := rwModel.Dimensions()
+	_, dc := rwModel.Dimensions()
 	if col < 0 || col > dc {
 		return errors.New("col out of bound")
 	}
 
-	rwModel.Resize(dr, dc+1)
-
-	for c := dc; c >= col; c-- {
-		for r := 0; r < dr; r++ {
-			if c == col {
-				rwModel.SetCellValue(r, c, "")
-			} else {
-				rwModel.SetCellValue(r, c, rwModel.CellValue(r, c-1))
-			}
-		}
-	}
+	snapshot := sliceSnapshot{attrs: DefaultColAttrs}
+	gvm.insertSlice(colAxis, col, snapshot)
+	gvm.journal.record(sliceChange{axis: colAxis, index: col, inserted: true, snapshot: snapshot})
 
 	return nil
 }
@@ -111,16 +189,13 @@ func (gvm *ModelViewCtrl) DeleteRow(row int) error {
 		return ErrModelReadOnly
 	}
 
-	h, w := rwModel.Dimensions()
-	for r := row; r < h-1; r++ {
-		for c := 0; c < w; c++ {
-			rwModel.SetCellValue(r, c, rwModel.CellValue(r+1, c))
-			gvm.rowAttrs[r] = gvm.rowAttrs[r+1]
-		}
+	h, _ := rwModel.Dimensions()
+	if row < 0 || row >= h {
+		return errors.New("row out of bound")
 	}
 
-	rwModel.Resize(h-1, w)
-	gvm.modelWasResized()
+	snapshot := gvm.removeSlice(rowAxis, row)
+	gvm.journal.record(sliceChange{axis: rowAxis, index: row, snapshot: snapshot})
 	return nil
 }
 
@@ -131,17 +206,295 @@ func (gvm *ModelViewCtrl) DeleteCol(col int) error {
 		return ErrModelReadOnly
 	}
 
-	h, w := rwModel.Dimensions()
-	for c := col; c < w-1; c++ {
+	_, w := rwModel.Dimensions()
+	if col < 0 || col >= w {
+		return errors.New("col out of bound")
+	}
+
+	snapshot := gvm.removeSlice(colAxis, col)
+	gvm.journal.record(sliceChange{axis: colAxis, index: col, snapshot: snapshot})
+	return nil
+}
+
+// SortRows sorts the rows of the model by the sort keys.  Rows before fromRow, such as a header, are
+// left in place.  The attributes of each row are moved along with it.
+func (gvm *ModelViewCtrl) SortRows(keys []SortKey, fromRow int) error {
+	rwModel, isRWModel := gvm.model.(RWModel)
+	if !isRWModel {
+		return ErrModelReadOnly
+	}
+
+	rows, cols := rwModel.Dimensions()
+	for _, key := range keys {
+		if key.Col < 0 || key.Col >= cols {
+			return errors.New("col out of bound")
+		}
+	}
+	if fromRow < 0 || fromRow >= rows {
+		return nil
+	}
+
+	order := sortedRowOrder(rwModel, fromRow, keys)
+	change := rowOrderChange{fromRow: fromRow, order: order}
+	if change.isIdentity() {
+		return nil
+	}
+
+	change.redo(gvm)
+	gvm.journal.record(change)
+	return nil
+}
+
+// FilterRows hides the rows for which the predicate returns false.  Rows before fromRow, such as a header,
+// are left visible.  Rows which are already hidden remain hidden, so filters can be combined.  Filtering
+// does not change the model.
+func (gvm *ModelViewCtrl) FilterRows(fromRow int, pred func(row int) bool) {
+	if fromRow < 0 {
+		fromRow = 0
+	}
+	for r := fromRow; r < len(gvm.rowAttrs); r++ {
+		if gvm.rowAttrs[r].Size != 0 && !pred(r) {
+			gvm.rowAttrs[r].Size = 0
+		}
+	}
+}
+
+// Unfilter shows all rows hidden by FilterRows.
+func (gvm *ModelViewCtrl) Unfilter() {
+	for r := range gvm.rowAttrs {
+		if gvm.rowAttrs[r].Size == 0 {
+			gvm.rowAttrs[r].Size = DefaultRowAttrs.Size
+		}
+	}
+}
+
+// VisibleRowCount returns the number of rows which are not hidden.
+func (gvm *ModelViewCtrl) VisibleRowCount() int {
+	count := 0
+	for _, attrs := range gvm.rowAttrs {
+		if attrs.Size != 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// VisibleRows returns the rows from row1 to row2 which are not hidden.
+func (gvm *ModelViewCtrl) VisibleRows(row1, row2 int) []int {
+	rows := make([]int, 0)
+	for r := row1; r <= row2; r++ {
+		if gvm.RowAttrs(r).Size != 0 {
+			rows = append(rows, r)
+		}
+	}
+	return rows
+}
+
+// FitColWidth sets the width of a column to fit its values, within the bounds of minWidth and maxWidth.
+// For large models, the width is determined from a sample of the rows, which always includes the
+// header rows.
+func (gvm *ModelViewCtrl) FitColWidth(col, minWidth, maxWidth int) {
+	rows, cols := gvm.model.Dimensions()
+	if col < 0 || col >= cols {
+		return
+	}
+
+	step := 1
+	if rows > maxFitSampleRows {
+		step = rows / maxFitSampleRows
+	}
+
+	textWidth := 0
+	for r := 0; r < rows; r++ {
+		if r >= gvm.headerRows && (r-gvm.headerRows)%step != 0 {
+			continue
+		}
+		if w := ui.TextWidth(gvm.model.CellValue(r, col)); w > textWidth {
+			textWidth = w
+		}
+	}
+
+	// Allow for the gap between columns
+	width := textWidth + 1
+	if width > maxWidth {
+		width = maxWidth
+	}
+	if width < minWidth {
+		width = minWidth
+	}
+
+	attrs := gvm.ColAttrs(col)
+	attrs.Size = width
+	gvm.SetColAttrs(col, attrs)
+}
+
+// BeginChangeGroup starts a group of changes which will be undone as a single step.  Groups
+// can be nested, with the step being recorded when the outermost group is ended.
+func (gvm *ModelViewCtrl) BeginChangeGroup() {
+	gvm.journal.beginGroup()
+}
+
+// EndChangeGroup ends a group of changes started by BeginChangeGroup.
+func (gvm *ModelViewCtrl) EndChangeGroup() {
+	gvm.journal.endGroup()
+}
+
+// Undo reverts the last step of changes made to the model.
+func (gvm *ModelViewCtrl) Undo() error {
+	if _, isRWModel := gvm.model.(RWModel); !isRWModel {
+		return ErrModelReadOnly
+	}
+
+	j := &gvm.journal
+	if len(j.undoStack) == 0 {
+		return ErrNothingToUndo
+	}
+
+	group := j.undoStack[len(j.undoStack)-1]
+	j.undoStack = j.undoStack[:len(j.undoStack)-1]
+	for i := len(group) - 1; i >= 0; i-- {
+		group[i].undo(gvm)
+	}
+	j.redoStack = append(j.redoStack, group)
+	return nil
+}
+
+// Redo reapplies the last step of changes reverted by Undo.
+func (gvm *ModelViewCtrl) Redo() error {
+	if _, isRWModel := gvm.model.(RWModel); !isRWModel {
+		return ErrModelReadOnly
+	}
+
+	j := &gvm.journal
+	if len(j.redoStack) == 0 {
+		return ErrNothingToRedo
+	}
+
+	group := j.redoStack[len(j.redoStack)-1]
+	j.redoStack = j.redoStack[:len(j.redoStack)-1]
+	for _, change := range group {
+		change.redo(gvm)
+	}
+	j.undoStack = append(j.undoStack, group)
+	return nil
+}
+
+// Returns the model as a RWModel.  Only to be used once it is known that the model is writable.
+func (gvm *ModelViewCtrl) rwModel() RWModel {
+	return gvm.model.(RWModel)
+}
+
+// Resizes the model without recording the change
+func (gvm *ModelViewCtrl) resizeModel(rows, cols int) {
+	gvm.rwModel().Resize(rows, cols)
+	gvm.modelWasResized()
+}
+
+// Removes a row or column from the model without recording the change.  Returns a snapshot
+// of the removed slice.
+func (gvm *ModelViewCtrl) removeSlice(axis sliceAxis, idx int) sliceSnapshot {
+	rw := gvm.rwModel()
+	h, w := rw.Dimensions()
+
+	var snapshot sliceSnapshot
+	if axis == rowAxis {
+		snapshot.attrs = gvm.RowAttrs(idx)
+		snapshot.values = make([]string, w)
+		for c := 0; c < w; c++ {
+			snapshot.values[c] = rw.CellValue(idx, c)
+		}
+
+		for r := idx; r < h-1; r++ {
+			for c := 0; c < w; c++ {
+				rw.SetCellValue(r, c, rw.CellValue(r+1, c))
+			}
+			gvm.rowAttrs[r] = gvm.rowAttrs[r+1]
+		}
+		gvm.resizeModel(h-1, w)
+	} else {
+		snapshot.attrs = gvm.ColAttrs(idx)
+		snapshot.values = make([]string, h)
 		for r := 0; r < h; r++ {
-			rwModel.SetCellValue(r, c, rwModel.CellValue(r, c+1))
+			snapshot.values[r] = rw.CellValue(r, idx)
+		}
+
+		for c := idx; c < w-1; c++ {
+			for r := 0; r < h; r++ {
+				rw.SetCellValue(r, c, rw.CellValue(r, c+1))
+			}
 			gvm.colAttrs[c] = gvm.colAttrs[c+1]
 		}
+		gvm.resizeModel(h, w-1)
 	}
 
-	rwModel.Resize(h, w-1)
-	gvm.modelWasResized()
-	return nil
+	return snapshot
+}
+
+// Inserts a row or column into the model without recording the change.  The new slice will
+// be populated from the snapshot.
+func (gvm *ModelViewCtrl) insertSlice(axis sliceAxis, idx int, snapshot sliceSnapshot) {
+	rw := gvm.rwModel()
+	h, w := rw.Dimensions()
+
+	if axis == rowAxis {
+		if h == 0 {
+			w = len(snapshot.values)
+		}
+		gvm.resizeModel(h+1, w)
+
+		for r := h; r > idx; r-- {
+			for c := 0; c < w; c++ {
+				rw.SetCellValue(r, c, rw.CellValue(r-1, c))
+			}
+			gvm.rowAttrs[r] = gvm.rowAttrs[r-1]
+		}
+		for c := 0; c < w; c++ {
+			rw.SetCellValue(idx, c, sliceSnapshotValue(snapshot, c))
+		}
+		gvm.rowAttrs[idx] = snapshot.attrs
+	} else {
+		gvm.resizeModel(h, w+1)
+
+		for c := w; c > idx; c-- {
+			for r := 0; r < h; r++ {
+				rw.SetCellValue(r, c, rw.CellValue(r, c-1))
+			}
+			gvm.colAttrs[c] = gvm.colAttrs[c-1]
+		}
+		for r := 0; r < h; r++ {
+			rw.SetCellValue(r, idx, sliceSnapshotValue(snapshot, r))
+		}
+		gvm.colAttrs[idx] = snapshot.attrs
+	}
+}
+
+// Reorders the rows of the model without recording the change.  Row fromRow+i will be set to the
+// row currently at order[i].
+func (gvm *ModelViewCtrl) reorderRows(fromRow int, order []int) {
+	rw := gvm.rwModel()
+	_, w := rw.Dimensions()
+
+	snapshots := make([]sliceSnapshot, len(order))
+	for i, r := range order {
+		snapshots[i] = sliceSnapshot{values: make([]string, w), attrs: gvm.RowAttrs(r)}
+		for c := 0; c < w; c++ {
+			snapshots[i].values[c] = rw.CellValue(r, c)
+		}
+	}
+
+	for i, snapshot := range snapshots {
+		for c, value := range snapshot.values {
+			rw.SetCellValue(fromRow+i, c, value)
+		}
+		gvm.rowAttrs[fromRow+i] = snapshot.attrs
+	}
+}
+
+func sliceSnapshotValue(snapshot sliceSnapshot, i int) string {
+	if i < len(snapshot.values) {
+		return snapshot.values[i]
+	}
+	return ""
 }
 
 func (gvm *ModelViewCtrl) modelWasResized() {
@@ -183,7 +536,12 @@ const (
 	MarkerBlue
 )
 
+// The maximum number of rows sampled when fitting the width of a column
+const maxFitSampleRows = 1000
+
 var DefaultRowAttrs = SliceAttr{Size: 1}
 var DefaultColAttrs = SliceAttr{Size: 24}
 
 var ErrModelReadOnly = errors.New("ModelVC is read-only")
+var ErrNothingToUndo = errors.New("Nothing to undo")
+var ErrNothingToRedo = errors.New("Nothing to redo")
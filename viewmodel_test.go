@@ -85,6 +85,317 @@ func TestModelViewCtrl_OpenRight(t *testing.T) {
 	})
 }
 
+func TestModelViewCtrl_Undo(t *testing.T) {
+	newModel := func() *StdModel {
+		return NewStdModelFromSlice([][]string{
+			{"letters", "numbers", "greek"},
+			{"a", "1", "alpha"},
+			{"b", "2", "bravo"},
+		})
+	}
+	original := [][]string{
+		{"letters", "numbers", "greek"},
+		{"a", "1", "alpha"},
+		{"b", "2", "bravo"},
+	}
+
+	t.Run("should undo and redo cell changes", func(t *testing.T) {
+		rwModel := newModel()
+		mvc := NewGridViewModel(rwModel)
+
+		assert.NoError(t, mvc.SetCellValue(1, 1, "one"))
+		assert.NoError(t, mvc.SetCellValue(2, 1, "two"))
+
+		assert.NoError(t, mvc.Undo())
+		assertModel(t, rwModel, [][]string{
+			{"letters", "numbers", "greek"},
+			{"a", "one", "alpha"},
+			{"b", "2", "bravo"},
+		})
+
+		assert.NoError(t, mvc.Undo())
+		assertModel(t, rwModel, original)
+		assert.Equal(t, ErrNothingToUndo, mvc.Undo())
+
+		assert.NoError(t, mvc.Redo())
+		assert.NoError(t, mvc.Redo())
+		assertModel(t, rwModel, [][]string{
+			{"letters", "numbers", "greek"},
+			{"a", "one", "alpha"},
+			{"b", "two", "bravo"},
+		})
+		assert.Equal(t, ErrNothingToRedo, mvc.Redo())
+	})
+
+	t.Run("should restore deleted rows with their attributes", func(t *testing.T) {
+		rwModel := newModel()
+		mvc := NewGridViewModel(rwModel)
+		mvc.SetRowAttrs(1, SliceAttr{Size: 1, Marker: MarkerRed})
+
+		assert.NoError(t, mvc.DeleteRow(1))
+		assertModel(t, rwModel, [][]string{
+			{"letters", "numbers", "greek"},
+			{"b", "2", "bravo"},
+		})
+		assert.Equal(t, MarkerNone, mvc.RowAttrs(1).Marker)
+
+		assert.NoError(t, mvc.Undo())
+		assertModel(t, rwModel, original)
+		assert.Equal(t, MarkerRed, mvc.RowAttrs(1).Marker)
+	})
+
+	t.Run("should restore deleted columns with their attributes", func(t *testing.T) {
+		rwModel := newModel()
+		mvc := NewGridViewModel(rwModel)
+		mvc.SetColAttrs(0, SliceAttr{Size: 10})
+
+		assert.NoError(t, mvc.DeleteCol(0))
+		assert.NoError(t, mvc.Undo())
+		assertModel(t, rwModel, original)
+		assert.Equal(t, 10, mvc.ColAttrs(0).Size)
+
+		assert.NoError(t, mvc.Redo())
+		assertModel(t, rwModel, [][]string{
+			{"numbers", "greek"},
+			{"1", "alpha"},
+			{"2", "bravo"},
+		})
+	})
+
+	t.Run("should undo inserted columns", func(t *testing.T) {
+		rwModel := newModel()
+		mvc := NewGridViewModel(rwModel)
+
+		assert.NoError(t, mvc.OpenRight(0))
+		assert.NoError(t, mvc.Undo())
+		assertModel(t, rwModel, original)
+	})
+
+	t.Run("should restore cells dropped by a resize", func(t *testing.T) {
+		rwModel := newModel()
+		mvc := NewGridViewModel(rwModel)
+
+		assert.NoError(t, mvc.Resize(1, 1))
+		assert.NoError(t, mvc.Undo())
+		assertModel(t, rwModel, original)
+	})
+
+	t.Run("should undo grouped changes as a single step", func(t *testing.T) {
+		rwModel := newModel()
+		mvc := NewGridViewModel(rwModel)
+
+		mvc.BeginChangeGroup()
+		assert.NoError(t, mvc.SetCellValue(1, 0, "A"))
+		assert.NoError(t, mvc.SetCellValue(2, 0, "B"))
+		assert.NoError(t, mvc.DeleteCol(2))
+		mvc.EndChangeGroup()
+
+		assert.NoError(t, mvc.Undo())
+		assertModel(t, rwModel, original)
+		assert.Equal(t, ErrNothingToUndo, mvc.Undo())
+	})
+
+	t.Run("should clear redo steps when a new change is made", func(t *testing.T) {
+		rwModel := newModel()
+		mvc := NewGridViewModel(rwModel)
+
+		assert.NoError(t, mvc.SetCellValue(1, 0, "A"))
+		assert.NoError(t, mvc.Undo())
+		assert.NoError(t, mvc.SetCellValue(2, 0, "B"))
+		assert.Equal(t, ErrNothingToRedo, mvc.Redo())
+	})
+}
+
+func TestModelViewCtrl_SortRows(t *testing.T) {
+	newModel := func() *StdModel {
+		return NewStdModelFromSlice([][]string{
+			{"name", "qty", "team"},
+			{"carol", "10", "red"},
+			{"alice", "9", "blue"},
+			{"bob", "100", "red"},
+			{"dave", "", "blue"},
+		})
+	}
+
+	t.Run("should sort rows by a column with the header in place", func(t *testing.T) {
+		rwModel := newModel()
+		mvc := NewGridViewModel(rwModel)
+
+		assert.NoError(t, mvc.SortRows([]SortKey{{Col: 1}}, 1))
+		assertModel(t, rwModel, [][]string{
+			{"name", "qty", "team"},
+			{"alice", "9", "blue"},
+			{"carol", "10", "red"},
+			{"bob", "100", "red"},
+			{"dave", "", "blue"},
+		})
+	})
+
+	t.Run("should sort NaN after the numbers of a column", func(t *testing.T) {
+		rwModel := NewStdModelFromSlice([][]string{{"3"}, {"NaN"}, {"1"}, {"2"}, {"nan"}, {"0"}})
+		mvc := NewGridViewModel(rwModel)
+
+		assert.NoError(t, mvc.SortRows([]SortKey{{Col: 0}}, 0))
+		assertModel(t, rwModel, [][]string{{"0"}, {"1"}, {"2"}, {"3"}, {"NaN"}, {"nan"}})
+	})
+
+	t.Run("should sort rows by multiple columns", func(t *testing.T) {
+		rwModel := newModel()
+		mvc := NewGridViewModel(rwModel)
+
+		assert.NoError(t, mvc.SortRows([]SortKey{{Col: 2, Descending: true}, {Col: 0}}, 1))
+		assertModel(t, rwModel, [][]string{
+			{"name", "qty", "team"},
+			{"bob", "100", "red"},
+			{"carol", "10", "red"},
+			{"alice", "9", "blue"},
+			{"dave", "", "blue"},
+		})
+	})
+
+	t.Run("should move row attributes with their rows", func(t *testing.T) {
+		rwModel := newModel()
+		mvc := NewGridViewModel(rwModel)
+		mvc.SetRowAttrs(1, SliceAttr{Size: 1, Marker: MarkerRed})
+		mvc.SetRowAttrs(3, SliceAttr{Size: 1, Marker: MarkerBlue})
+
+		assert.NoError(t, mvc.SortRows([]SortKey{{Col: 0}}, 1))
+		assert.Equal(t, MarkerNone, mvc.RowAttrs(1).Marker)
+		assert.Equal(t, MarkerBlue, mvc.RowAttrs(2).Marker)
+		assert.Equal(t, MarkerRed, mvc.RowAttrs(3).Marker)
+	})
+
+	t.Run("should undo and redo a sort", func(t *testing.T) {
+		rwModel := newModel()
+		mvc := NewGridViewModel(rwModel)
+		mvc.SetRowAttrs(1, SliceAttr{Size: 1, Marker: MarkerRed})
+
+		assert.NoError(t, mvc.SortRows([]SortKey{{Col: 0, Descending: true}}, 0))
+		assert.NoError(t, mvc.Undo())
+		assertModel(t, rwModel, [][]string{
+			{"name", "qty", "team"},
+			{"carol", "10", "red"},
+			{"alice", "9", "blue"},
+			{"bob", "100", "red"},
+			{"dave", "", "blue"},
+		})
+		assert.Equal(t, MarkerRed, mvc.RowAttrs(1).Marker)
+
+		assert.NoError(t, mvc.Redo())
+		assertModel(t, rwModel, [][]string{
+			{"name", "qty", "team"},
+			{"dave", "", "blue"},
+			{"carol", "10", "red"},
+			{"bob", "100", "red"},
+			{"alice", "9", "blue"},
+		})
+		assert.Equal(t, MarkerRed, mvc.RowAttrs(2).Marker)
+	})
+
+	t.Run("should not record a sort which leaves the rows in place", func(t *testing.T) {
+		mvc := NewGridViewModel(newModel())
+
+		assert.NoError(t, mvc.SortRows([]SortKey{{Col: 1}}, 4))
+		assert.Equal(t, ErrNothingToUndo, mvc.Undo())
+	})
+
+	t.Run("should return an error for an invalid column", func(t *testing.T) {
+		mvc := NewGridViewModel(newModel())
+
+		assert.Error(t, mvc.SortRows([]SortKey{{Col: 3}}, 0))
+	})
+}
+
+func TestModelViewCtrl_ColumnIndex(t *testing.T) {
+	mvc := NewGridViewModel(NewStdModelFromSlice([][]string{
+		{"name", "Qty", "2", "qty"},
+		{"alice", "1", "x", "y"},
+	}))
+
+	t.Run("should only refer to columns by index without a header", func(t *testing.T) {
+		col, err := mvc.ColumnIndex("2")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, col)
+
+		_, err = mvc.ColumnIndex("name")
+		assert.Error(t, err)
+	})
+
+	t.Run("should refer to columns by header name", func(t *testing.T) {
+		mvc.SetHeader(1, 0)
+		defer mvc.SetHeader(0, 0)
+
+		scenarios := []struct {
+			ref      string
+			expected int
+		}{
+			{"name", 0},
+			{"NAME", 0},
+			{"qty", 3},
+			{"QTY", 1},
+			{"2", 2},
+			{"1", 1},
+		}
+		for _, scenario := range scenarios {
+			col, err := mvc.ColumnIndex(scenario.ref)
+			assert.NoError(t, err, scenario.ref)
+			assert.Equal(t, scenario.expected, col, scenario.ref)
+		}
+
+		for _, ref := range []string{"", "age", "4", "-1"} {
+			_, err := mvc.ColumnIndex(ref)
+			assert.Error(t, err, ref)
+		}
+	})
+}
+
+func TestModelViewCtrl_FitColWidth(t *testing.T) {
+	t.Run("should fit the width of the column to its values", func(t *testing.T) {
+		mvc := NewGridViewModel(NewStdModelFromSlice([][]string{
+			{"name", "city"},
+			{"alice", "東京"},
+			{"bartholomew", "Zürich"},
+		}))
+
+		mvc.FitColWidth(0, 4, 40)
+		mvc.FitColWidth(1, 4, 40)
+
+		assert.Equal(t, 12, mvc.ColAttrs(0).Size)
+		assert.Equal(t, 7, mvc.ColAttrs(1).Size)
+	})
+
+	t.Run("should keep the width within the bounds", func(t *testing.T) {
+		mvc := NewGridViewModel(NewStdModelFromSlice([][]string{
+			{"a", "a very long value which goes on"},
+		}))
+
+		mvc.FitColWidth(0, 4, 10)
+		mvc.FitColWidth(1, 4, 10)
+
+		assert.Equal(t, 4, mvc.ColAttrs(0).Size)
+		assert.Equal(t, 10, mvc.ColAttrs(1).Size)
+	})
+
+	t.Run("should sample the rows of large models", func(t *testing.T) {
+		values := make([][]string, maxFitSampleRows*5)
+		for r := range values {
+			values[r] = []string{"x"}
+		}
+		values[0][0] = "header"
+		values[2][0] = "not sampled"
+		mvc := NewGridViewModel(NewStdModelFromSlice(values))
+		mvc.SetHeader(1, 0)
+
+		mvc.FitColWidth(0, 1, 40)
+		assert.Equal(t, 7, mvc.ColAttrs(0).Size)
+
+		values[6][0] = "sampled value"
+		mvc.SetModel(NewStdModelFromSlice(values))
+		mvc.FitColWidth(0, 1, 40)
+		assert.Equal(t, 14, mvc.ColAttrs(0).Size)
+	})
+}
+
 func assertModel(t *testing.T, actual Model, expected [][]string) {
 	dr, dc := actual.Dimensions()
 	assert.Equalf(t, len(expected), dr, "number of rows in model")
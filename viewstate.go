@@ -0,0 +1,187 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Where the view state of files is kept
+const (
+	// The view state is not kept
+	ViewStateNone = "none"
+
+	// The view state is kept in a hidden file alongside the file, named .FILE.ted.json
+	ViewStateSidecar = "sidecar"
+
+	// The view state is kept in the per-user state directory, keyed by the path of the file
+	ViewStateUser = "user"
+)
+
+// The view state of a file, such as column widths and row markers, which is restored when the file
+// is opened again.
+type viewState struct {
+	CursorRow  int `json:"cursorRow"`
+	CursorCol  int `json:"cursorCol"`
+	HeaderRows int `json:"headerRows,omitempty"`
+	HeaderCols int `json:"headerCols,omitempty"`
+
+	Cols []colViewState `json:"cols"`
+	Rows []rowViewState `json:"rows,omitempty"`
+}
+
+type colViewState struct {
+	Width  int    `json:"width"`
+	Marker Marker `json:"marker,omitempty"`
+}
+
+// The marker of a row.  The key identifies the row by its values, so that the marker can be restored
+// to the row if it has moved since the state was saved.
+type rowViewState struct {
+	Row    int    `json:"row"`
+	Key    string `json:"key"`
+	Marker Marker `json:"marker"`
+}
+
+// Returns the path of the file holding the view state of a file
+func viewStatePath(filename string, location string) (string, error) {
+	absFilename, err := filepath.Abs(filename)
+	if err != nil {
+		return "", err
+	}
+
+	switch location {
+	case ViewStateSidecar:
+		return filepath.Join(filepath.Dir(absFilename), "."+filepath.Base(absFilename)+".ted.json"), nil
+	case ViewStateUser:
+		stateDir, err := userStateDir()
+		if err != nil {
+			return "", err
+		}
+		hash := sha1.Sum([]byte(absFilename))
+		return filepath.Join(stateDir, "ted", "views", hex.EncodeToString(hash[:])+".json"), nil
+	}
+	return "", fmt.Errorf("unrecognised view state location: %v", location)
+}
+
+// Returns the per-user state directory, which is $XDG_STATE_HOME or ~/.local/state
+func userStateDir() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return dir, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "state"), nil
+}
+
+// Captures the view state of the model
+func captureViewState(gvm *ModelViewCtrl, cursorRow, cursorCol int) *viewState {
+	state := &viewState{CursorRow: cursorRow, CursorCol: cursorCol}
+	state.HeaderRows, state.HeaderCols = gvm.Header()
+
+	model := gvm.Model()
+	rows, cols := model.Dimensions()
+	state.Cols = make([]colViewState, cols)
+	for c := range state.Cols {
+		attrs := gvm.ColAttrs(c)
+		state.Cols[c] = colViewState{Width: attrs.Size, Marker: attrs.Marker}
+	}
+	for r := 0; r < rows; r++ {
+		if marker := gvm.RowAttrs(r).Marker; marker != MarkerNone {
+			state.Rows = append(state.Rows, rowViewState{Row: r, Key: rowKey(model, r), Marker: marker})
+		}
+	}
+	return state
+}
+
+// Applies the view state to the model.  Row markers are restored to the row with the same values,
+// preferring the row at the same position.  Markers of rows which no longer exist are dropped.
+func (state *viewState) apply(gvm *ModelViewCtrl) {
+	gvm.SetHeader(state.HeaderRows, state.HeaderCols)
+
+	for c, col := range state.Cols {
+		gvm.SetColAttrs(c, SliceAttr{Size: col.Width, Marker: col.Marker})
+	}
+
+	model := gvm.Model()
+	rows, _ := model.Dimensions()
+	var rowsByKey map[string][]int
+	used := make(map[int]bool)
+	for _, row := range state.Rows {
+		r := row.Row
+		if r >= rows || used[r] || rowKey(model, r) != row.Key {
+			if rowsByKey == nil {
+				rowsByKey = make(map[string][]int)
+				for r := 0; r < rows; r++ {
+					key := rowKey(model, r)
+					rowsByKey[key] = append(rowsByKey[key], r)
+				}
+			}
+
+			r = -1
+			for _, candidate := range rowsByKey[row.Key] {
+				if !used[candidate] {
+					r = candidate
+					break
+				}
+			}
+			if r == -1 {
+				continue
+			}
+		}
+
+		used[r] = true
+		attrs := gvm.RowAttrs(r)
+		attrs.Marker = row.Marker
+		gvm.SetRowAttrs(r, attrs)
+	}
+}
+
+// Returns a key identifying a row by its values
+func rowKey(model Model, row int) string {
+	_, cols := model.Dimensions()
+	values := make([]string, cols)
+	for c := range values {
+		values[c] = model.CellValue(row, c)
+	}
+
+	hash := sha1.Sum([]byte(csvRecordKey(values)))
+	return hex.EncodeToString(hash[:8])
+}
+
+// Reads the view state from a file.  Returns nil if the file does not exist.
+func readViewState(path string) (*viewState, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	state := new(viewState)
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("invalid view state %v: %v", path, err)
+	}
+	return state, nil
+}
+
+// Writes the view state to a file, creating the directory if necessary.
+func writeViewState(path string, state *viewState) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	return atomicWriteFile(path, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(state)
+	})
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestViewState(t *testing.T) {
+	t.Run("should restore column widths and row markers", func(t *testing.T) {
+		mvc := NewGridViewModel(NewStdModelFromSlice([][]string{
+			{"name", "qty"}, {"alice", "1"}, {"bob", "2"}, {"carol", "3"},
+		}))
+		mvc.SetHeader(1, 0)
+		mvc.SetColAttrs(1, SliceAttr{Size: 8, Marker: MarkerGreen})
+		mvc.SetRowAttrs(1, SliceAttr{Size: 1, Marker: MarkerRed})
+		mvc.SetRowAttrs(3, SliceAttr{Size: 1, Marker: MarkerBlue})
+
+		state := captureViewState(mvc, 2, 1)
+		assert.Equal(t, 2, state.CursorRow)
+		assert.Equal(t, 1, state.CursorCol)
+
+		restored := NewGridViewModel(NewStdModelFromSlice([][]string{
+			{"name", "qty"}, {"alice", "1"}, {"bob", "2"}, {"carol", "3"},
+		}))
+		state.apply(restored)
+
+		rows, cols := restored.Header()
+		assert.Equal(t, 1, rows)
+		assert.Equal(t, 0, cols)
+		assert.Equal(t, DefaultColAttrs, restored.ColAttrs(0))
+		assert.Equal(t, SliceAttr{Size: 8, Marker: MarkerGreen}, restored.ColAttrs(1))
+		assert.Equal(t, MarkerRed, restored.RowAttrs(1).Marker)
+		assert.Equal(t, MarkerNone, restored.RowAttrs(2).Marker)
+		assert.Equal(t, MarkerBlue, restored.RowAttrs(3).Marker)
+	})
+
+	t.Run("should restore row markers to rows which have moved", func(t *testing.T) {
+		mvc := NewGridViewModel(NewStdModelFromSlice([][]string{
+			{"alice", "1"}, {"bob", "2"}, {"carol", "3"},
+		}))
+		mvc.SetRowAttrs(0, SliceAttr{Size: 1, Marker: MarkerRed})
+		mvc.SetRowAttrs(2, SliceAttr{Size: 1, Marker: MarkerBlue})
+		state := captureViewState(mvc, 0, 0)
+
+		restored := NewGridViewModel(NewStdModelFromSlice([][]string{
+			{"carol", "3"}, {"dave", "4"}, {"bob", "2"},
+		}))
+		state.apply(restored)
+
+		assert.Equal(t, MarkerBlue, restored.RowAttrs(0).Marker)
+		assert.Equal(t, MarkerNone, restored.RowAttrs(1).Marker)
+		assert.Equal(t, MarkerNone, restored.RowAttrs(2).Marker)
+	})
+
+	t.Run("should write and read the state", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "views", "state.json")
+		state := &viewState{CursorRow: 3, Cols: []colViewState{{Width: 12}}, Rows: []rowViewState{{Row: 1, Key: "abc", Marker: MarkerGreen}}}
+
+		assert.NoError(t, writeViewState(path, state))
+		read, err := readViewState(path)
+
+		assert.NoError(t, err)
+		assert.Equal(t, state, read)
+	})
+
+	t.Run("should return nil if there is no state", func(t *testing.T) {
+		state, err := readViewState(filepath.Join(t.TempDir(), "state.json"))
+
+		assert.NoError(t, err)
+		assert.Nil(t, state)
+	})
+}
+
+func TestViewStatePath(t *testing.T) {
+	t.Run("should return a hidden file alongside the file", func(t *testing.T) {
+		path, err := viewStatePath("/data/files/report.csv", ViewStateSidecar)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "/data/files/.report.csv.ted.json", path)
+	})
+
+	t.Run("should return a file in the user state directory", func(t *testing.T) {
+		stateDir := t.TempDir()
+		os.Setenv("XDG_STATE_HOME", stateDir)
+		defer os.Unsetenv("XDG_STATE_HOME")
+
+		path1, err := viewStatePath("/data/files/report.csv", ViewStateUser)
+		assert.NoError(t, err)
+		path2, err := viewStatePath("/data/other/report.csv", ViewStateUser)
+		assert.NoError(t, err)
+
+		assert.Equal(t, filepath.Join(stateDir, "ted", "views"), filepath.Dir(path1))
+		assert.NotEqual(t, path1, path2)
+	})
+
+	t.Run("should return an error for unrecognised locations", func(t *testing.T) {
+		_, err := viewStatePath("report.csv", "elsewhere")
+		assert.Error(t, err)
+	})
+}